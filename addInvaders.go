@@ -17,10 +17,8 @@ func addClusterHeads(t *testing.T) {
 func addInvaders(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
-	var (
-		err   error
-		check bool
-	)
+	var err error
+
 	nodesToCheck := make([]uint64, 0)
 	for _, i := range Env.Invaders {
 		if Nodes[NodebyHostIP[i.HostIp]] != nil {
@@ -40,57 +38,47 @@ func addInvaders(t *testing.T) {
 			Nodes[node.Id] = &node
 			fmt.Printf("Add Cluster Head id %v to Nodes\n", node.Id)
 			NodebyHostIP[node.Host] = node.Id
+			tagNode(&node)
 			fmt.Printf("Mapping hostIP %v to id %v\n",
 				node.Host, node.Id)
 		}
 	}
 
 	// early check for add fail
-	time.Sleep(10 * time.Second)
+	Pcc.Sleep(10 * time.Second)
 	for id := range Nodes {
 		if status, err := Pcc.GetProvisionStatus(id); err == nil {
-			if strings.Contains(status, "Add node failed") {
+			if strings.Contains(status, pcc.PROVISION_STATUS_ADD_FAILED) {
 				assert.Fatalf("%v for %v\n", status, id)
 			}
 		}
 	}
 
-	from := time.Now()
+	// from before both waits, as the collector may be installed while
+	// waiting for the agent
+	from := Pcc.Clock().Now()
 	//Check Agent installation
-	//SERIAL - to be improved
-	for i := 0; i < len(nodesToCheck); i++ {
-		check = false
-		fmt.Printf("Checking Agent installation for nodeId:%v\n",
-			nodesToCheck[i])
-		check, err = checkGenericInstallation(nodesToCheck[i],
-			AGENT_TIMEOUT, AGENT_NOTIFICATION, from)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-		}
-		if check {
-			fmt.Printf("AGENT correctly installed on nodeId:%v\n",
-				nodesToCheck[i])
-		}
+	fmt.Printf("Checking Agent installation for nodeIds:%v\n",
+		nodesToCheck)
+	r, err := checkInstallations(nodesToCheck, pcc.AGENT_INSTALL_EVENT,
+		from)
+	if err != nil || len(r.Failed()) > 0 {
+		assert.Fatalf("Agent installation failed: %v\n", err)
+		return
 	}
 
-	from = time.Now()
 	//Check Collector installation
-	for i := 0; i < len(nodesToCheck); i++ {
-		fmt.Printf("Checking Collector installation for nodeId:%v\n",
-			nodesToCheck[i])
-		check, err = checkGenericInstallation(nodesToCheck[i],
-			COLLECTOR_TIMEOUT, COLLECTOR_NOTIFICATION, from)
-		if err != nil {
-			fmt.Printf("%v\n", err)
-		}
-		if check {
-			fmt.Printf("COLLECTOR correctly installed on nodeId:"+
-				":%v\n", nodesToCheck[i])
-		}
+	fmt.Printf("Checking Collector installation for nodeIds:%v\n",
+		nodesToCheck)
+	r, err = checkInstallations(nodesToCheck, pcc.COLLECTOR_INSTALL_EVENT,
+		from)
+	if err != nil || len(r.Failed()) > 0 {
+		assert.Fatalf("Collector installation failed: %v\n", err)
+		return
 	}
 
 	// waiting for node becomes online
-	start := time.Now()
+	start := Pcc.Clock().Now()
 	done := false
 	timeout := 180 * time.Second
 	for !done {
@@ -116,18 +104,19 @@ func addInvaders(t *testing.T) {
 			}
 			fmt.Printf("%v is %v provisionStatus = %v \n", name,
 				connection, node.ProvisionStatus)
-			if node.ProvisionStatus == "Add node failed" {
+			if node.ProvisionStatus == pcc.PROVISION_STATUS_ADD_FAILED {
 				assert.Fatalf("%v for %v\n",
 					node.ProvisionStatus, name)
 			}
 			done = connection == "online"
 			Nodes[id] = node
+			tagNode(node)
 		}
 
 		if !done {
-			time.Sleep(10 * time.Second)
+			Pcc.Sleep(10 * time.Second)
 		}
-		if time.Since(start) > timeout {
+		if Pcc.Since(start) > timeout {
 			assert.Fatalf("timeout")
 			break
 		}
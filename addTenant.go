@@ -116,7 +116,7 @@ func addTenantA(t *testing.T) {
 		}
 	}
 
-	source := fmt.Sprintf("https://%v:9999/gui/setPass", Env.PccIp)
+	source := Pcc.URL("gui/setPass")
 	addUser := pcc.AddUser{
 		UserName:  "bsimpson@platinasystems.com",
 		FirstName: "Bart",
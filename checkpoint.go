@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/platinasystems/test"
+)
+
+const (
+	STATE_NODES         = "nodes"
+	STATE_SECURITY_KEYS = "securityKeys"
+	STATE_AUTH_PROFILE  = "authProfile"
+	STATE_PORTUS_NODES  = "portusNodes"
+	STATE_PXE_BOOT_NODE = "pxeBootNode"
+)
+
+var (
+	checkpointFile = flag.String("checkpoint", "",
+		"file the progress of a plan is saved to after each step, "+
+			"none if empty")
+	resumeFlag = flag.Bool("resume", false,
+		"resume the plan at the first step not done in -checkpoint")
+	resumed bool
+)
+
+// planState is state the steps share: the globals saved in the
+// checkpoint, and read back from PCC by rehydrate, if set, on resume.
+type planState struct {
+	vars      []interface{}
+	rehydrate func() error
+}
+
+var states = map[string]planState{
+	STATE_NODES: {
+		vars:      []interface{}{&Nodes, &NodebyHostIP},
+		rehydrate: loadNodes,
+	},
+	STATE_SECURITY_KEYS: {
+		vars:      []interface{}{&SecurityKeys},
+		rehydrate: loadSecurityKeys,
+	},
+	STATE_AUTH_PROFILE: {
+		vars: []interface{}{&CurrentAuthProfileName},
+	},
+	STATE_PORTUS_NODES: {
+		vars: []interface{}{&PortusSelectedNodeIds},
+	},
+	STATE_PXE_BOOT_NODE: {
+		vars: []interface{}{&PxeBootSelectedNodeId},
+	},
+}
+
+type checkpointStep struct {
+	Name string `json:"name"`
+	Done bool   `json:"done"`
+}
+
+// checkpoint is the progress of a plan: the steps done, and the state
+// they produced.
+type checkpoint struct {
+	Plan  string                       `json:"plan"`
+	Time  time.Time                    `json:"time"`
+	Steps []checkpointStep             `json:"steps"`
+	State map[string][]json.RawMessage `json:"state"`
+}
+
+// startCheckpoint returns the checkpoint of plan, that of -checkpoint,
+// with its state restored, when resuming, else a new one.  -resume needs
+// -checkpoint.
+func startCheckpoint(plan testPlan) (cp *checkpoint, err error) {
+	cp = &checkpoint{
+		Plan:  plan.Name,
+		State: make(map[string][]json.RawMessage),
+	}
+	for _, s := range plan.Steps {
+		cp.Steps = append(cp.Steps, checkpointStep{Name: s.name()})
+	}
+	if !*resumeFlag || resumed {
+		return
+	}
+	if *checkpointFile == "" {
+		err = fmt.Errorf("-resume needs the -checkpoint to resume from")
+		return
+	}
+	// only the first run of a soak resumes
+	resumed = true
+
+	var (
+		b     []byte
+		saved checkpoint
+	)
+
+	if b, err = ioutil.ReadFile(*checkpointFile); err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &saved); err != nil {
+		err = fmt.Errorf("%v: %v", *checkpointFile, err)
+		return
+	}
+	if saved.Plan != cp.Plan || len(saved.Steps) != len(cp.Steps) {
+		err = fmt.Errorf("%v is a checkpoint of another plan",
+			*checkpointFile)
+		return
+	}
+	for i, s := range saved.Steps {
+		if s.Name != cp.Steps[i].Name {
+			err = fmt.Errorf("%v is a checkpoint of another plan",
+				*checkpointFile)
+			return
+		}
+	}
+	cp.Steps = saved.Steps
+	fmt.Printf("Resuming plan %v from %v of %v\n", plan.Name,
+		*checkpointFile, saved.Time.Format(timeFormat))
+
+	for i, s := range plan.Steps {
+		if !cp.Steps[i].Done {
+			continue
+		}
+		for _, name := range steps[s.Step].produces {
+			if err = cp.restore(name, saved.State[name]); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// restore sets the state name as saved, then as PCC has it if it can be
+// read back from there.
+func (cp *checkpoint) restore(name string, saved []json.RawMessage) (
+	err error) {
+
+	if _, done := cp.State[name]; done {
+		return
+	}
+	state := states[name]
+	if len(saved) == len(state.vars) {
+		for i, v := range state.vars {
+			if err = json.Unmarshal(saved[i], v); err != nil {
+				err = fmt.Errorf("%v of %v: %v", name,
+					*checkpointFile, err)
+				return
+			}
+		}
+	}
+	cp.State[name] = saved
+	if state.rehydrate == nil || *test.DryRun {
+		fmt.Printf("Restored %v from the checkpoint\n", name)
+		return
+	}
+	if err = state.rehydrate(); err != nil {
+		fmt.Printf("Restored %v from the checkpoint, failed to get "+
+			"it from PCC: %v\n", name, err)
+		err = nil
+		return
+	}
+	fmt.Printf("Restored %v from PCC\n", name)
+	return
+}
+
+// done tells whether step i of the plan was done before it resumed.
+func (cp *checkpoint) done(i int) bool {
+	return cp.Steps[i].Done
+}
+
+// record records how step i went and writes the checkpoint, with the
+// state produced so far as it is now.
+func (cp *checkpoint) record(i int, produces []string, done bool) (
+	err error) {
+
+	cp.Steps[i].Done = done
+	if done {
+		for _, name := range produces {
+			cp.State[name] = nil
+		}
+	}
+	for name := range cp.State {
+		var saved []json.RawMessage
+
+		for _, v := range states[name].vars {
+			var b []byte
+
+			if b, err = json.Marshal(v); err != nil {
+				return
+			}
+			saved = append(saved, b)
+		}
+		cp.State[name] = saved
+	}
+	cp.Time = time.Now()
+	return cp.save()
+}
+
+// save writes the checkpoint to -checkpoint, if set, replacing it at
+// once.
+func (cp *checkpoint) save() (err error) {
+	if *checkpointFile == "" || *test.DryRun {
+		return
+	}
+	b, err := json.MarshalIndent(cp, "", "\t")
+	if err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(*checkpointFile),
+		"."+filepath.Base(*checkpointFile))
+	if err != nil {
+		return
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), *checkpointFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setCheckpoint sets -checkpoint and -resume as if not resumed yet, and
+// returns the func setting them back.
+func setCheckpoint(file string, resume bool) (restore func()) {
+	oldFile, oldResume, oldResumed := *checkpointFile, *resumeFlag, resumed
+	*checkpointFile, *resumeFlag, resumed = file, resume, false
+	return func() {
+		*checkpointFile, *resumeFlag, resumed = oldFile, oldResume,
+			oldResumed
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(name string) { CurrentAuthProfileName = name }(
+		CurrentAuthProfileName)
+
+	plan := testPlan{
+		Name: "profile",
+		Steps: []planStep{
+			{Step: "addProfile"},
+			{Step: "updateSecurityKey"},
+		},
+	}
+	file := filepath.Join(dir, "profile.json")
+	defer setCheckpoint(file, false)()
+	cp, err := startCheckpoint(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CurrentAuthProfileName = "ldap"
+	if err = cp.record(0, steps["addProfile"].produces, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = cp.record(1, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	CurrentAuthProfileName = ""
+	setCheckpoint(file, true)
+	if cp, err = startCheckpoint(plan); err != nil {
+		t.Fatal(err)
+	}
+	if !cp.done(0) || cp.done(1) {
+		t.Errorf("steps done %v, want only the first", cp.Steps)
+	}
+	if CurrentAuthProfileName != "ldap" {
+		t.Errorf("auth profile %q restored, want ldap",
+			CurrentAuthProfileName)
+	}
+
+	// another plan does not resume from it
+	plan.Name = "other"
+	setCheckpoint(file, true)
+	if _, err = startCheckpoint(plan); err == nil {
+		t.Error("resumed from the checkpoint of another plan")
+	}
+}
+
+func TestCheckpointUnset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	plan := testPlan{
+		Name:  "keys",
+		Steps: []planStep{{Step: "updateSecurityKey"}},
+	}
+	defer setCheckpoint("", false)()
+	cp, err := startCheckpoint(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cp.record(0, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%v written without -checkpoint", files[0].Name())
+	}
+
+	setCheckpoint("", true)
+	if _, err = startCheckpoint(plan); err == nil {
+		t.Error("resumed without -checkpoint")
+	}
+}
@@ -346,7 +346,7 @@ func verifyNetworkConfig(t *testing.T) {
 		if len(serverMap) == 0 {
 			allDone = true
 		} else {
-			time.Sleep(10 * time.Second)
+			Pcc.Sleep(10 * time.Second)
 		}
 		if loop >= loopLimit {
 			assert.Fatal("Timed out verifying intferface config\n")
@@ -365,8 +365,8 @@ func verifyNetworkInterfaces(t *testing.T) {
 		nodesToCheck[id] = 1
 	}
 
-	timeout := time.After(10 * time.Minute)
-	tick := time.Tick(5 * time.Second)
+	timeout := Pcc.Clock().After(10 * time.Minute)
+	tick := Pcc.Clock().After(5 * time.Second)
 	for {
 		select {
 		case <-timeout:
@@ -394,6 +394,7 @@ func verifyNetworkInterfaces(t *testing.T) {
 			assert.Fatalf("time out updating interfaces\n")
 			return
 		case <-tick:
+			tick = Pcc.Clock().After(5 * time.Second)
 			for id, intfs := range nodeIntfMap {
 				if _, found := nodesToCheck[id]; !found {
 					continue
@@ -447,8 +448,8 @@ func verifyNetworkUp(t *testing.T) {
 		nodesToCheck[id] = 1
 	}
 
-	timeout := time.After(5 * time.Minute)
-	tick := time.Tick(5 * time.Second)
+	timeout := Pcc.Clock().After(5 * time.Minute)
+	tick := Pcc.Clock().After(5 * time.Second)
 	for {
 		select {
 		case <-timeout:
@@ -479,6 +480,7 @@ func verifyNetworkUp(t *testing.T) {
 			assert.Fatalf("time out updating interfaces\n")
 			return
 		case <-tick:
+			tick = Pcc.Clock().After(5 * time.Second)
 			for id, intfs := range nodeIntfMap {
 				if _, found := nodesToCheck[id]; !found {
 					continue
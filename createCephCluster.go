@@ -93,7 +93,7 @@ func testCreateCephCluster(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	startTime = time.Now()
+	startTime = Pcc.Clock().Now()
 	err := createCephCluster(cephConfig)
 	if err != nil {
 		errMsg := fmt.Sprintf("%v", err)
@@ -121,6 +121,7 @@ func createCephCluster(cephConfig *pcc.CephConfiguration) (err error) {
 			}
 		}else {
 			cephConfig.SetCephClusterId(clusterId)
+			timeline.Tag(clusterId, pcc.TARGET_CLUSTER, createRequest.Name)
 			fmt.Println("Ceph cluster installation has started. Cluster id:", clusterId)
 		}
 	}
@@ -151,7 +152,7 @@ func testCreateCephPool(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	startTime = time.Now()
+	startTime = Pcc.Clock().Now()
 	err := createCephPool(cephConfig)
 	if err != nil {
 		errMsg := fmt.Sprintf("%v", err)
@@ -208,7 +209,7 @@ func testCreateCephFS(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	startTime = time.Now()
+	startTime = Pcc.Clock().Now()
 	err := createCephFS(cephConfig)
 	if err != nil {
 		errMsg := fmt.Sprintf("%v", err)
@@ -272,7 +273,7 @@ func testDeleteCephFS(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	startTime = time.Now()
+	startTime = Pcc.Clock().Now()
 	err := deleteCephFS(cephConfig)
 	if err != nil {
 		errMsg := fmt.Sprintf("%v", err)
@@ -284,7 +285,7 @@ func testDeleteCephFS(t *testing.T) {
 
 func deleteCephFS(cephConfig *pcc.CephConfiguration) (err error) {
 	fmt.Printf("Ceph FS [%v] deletion is starting\n", pcc.CEPH_FS_NAME)
-	time.Sleep(time.Second * 5)
+	Pcc.Sleep(time.Second * 5)
 
 	if clusterId := cephConfig.GetCephClusterId(); clusterId != 0 {
 		cephFS, errGet := Pcc.GetCephFS(pcc.CEPH_FS_NAME, clusterId)
@@ -313,7 +314,7 @@ func testDeleteCephPool(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	startTime = time.Now()
+	startTime = Pcc.Clock().Now()
 	err := deleteCephPool(cephConfig)
 	if err != nil {
 		errMsg := fmt.Sprintf("%v", err)
@@ -325,7 +326,7 @@ func testDeleteCephPool(t *testing.T) {
 
 func deleteCephPool(cephConfig *pcc.CephConfiguration) (errAggr error) {
 	fmt.Println("Ceph pools deletion is starting")
-	time.Sleep(time.Second * 5)
+	Pcc.Sleep(time.Second * 5)
 
 	if clusterId := cephConfig.GetCephClusterId(); clusterId != 0 {
 		for _, pools := range pcc.CephPools {
@@ -350,7 +351,7 @@ func testDeleteCephCluster(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	startTime = time.Now()
+	startTime = Pcc.Clock().Now()
 	err := deleteCephCluster(cephConfig)
 	if err != nil {
 		errMsg := fmt.Sprintf("%v", err)
@@ -362,7 +363,7 @@ func testDeleteCephCluster(t *testing.T) {
 
 func deleteCephCluster(cephConfig *pcc.CephConfiguration) (err error){
 	fmt.Printf("Ceph cluster [%v] uninstallation is starting\n", cephConfig.ClusterName)
-	time.Sleep(time.Second * 5)
+	Pcc.Sleep(time.Second * 5)
 
 	if clusterId := cephConfig.GetCephClusterId(); clusterId != 0 {
 		err = Pcc.DeleteCephCluster(clusterId)
@@ -526,12 +527,19 @@ func verifyCephInstallation(cephConfig *pcc.CephConfiguration) (err error) {
 		errMsg := fmt.Sprintf("Ceph cluster[%v] installation verification failed...ERROR: %v", cephConfig.ClusterName, err)
 		err = fmt.Errorf("%v", errMsg)
 	}else {
-		s, err := cephConfig.VerifyCeph(startTime, pcc.CEPH_CLUSTER_INSTALL_EVENT, cephConfig.ClusterName)
+		var (
+			seq pcc.Sequence
+			r   pcc.SequenceResult
+		)
+		if seq, err = cephConfig.InstallSequence(); err == nil {
+			r, err = Pcc.WaitForSequence(startTime, seq)
+			fmt.Println(r.String())
+		}
 		if err != nil {
 			errMsg := fmt.Sprintf("Ceph cluster[%v] installation verification failed...ERROR: %v", cephConfig.ClusterName, err)
 			err = fmt.Errorf("%v", errMsg)
 		}else {
-			fmt.Printf("Ceph cluster [%v] deployed properly..[%v]\n", cephConfig.ClusterName, s.Msg)
+			fmt.Printf("Ceph cluster [%v] deployed properly\n", cephConfig.ClusterName)
 		}
 	}
 	return
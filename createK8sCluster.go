@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 
@@ -10,9 +9,18 @@ import (
 	"github.com/platinasystems/test"
 )
 
-var k8sname string = "k8stest"
+const (
+	DEFAULT_K8S_NAME    = "k8stest"
+	DEFAULT_K8S_VERSION = "v1.14.3"
+	DEFAULT_K8S_CNI     = "kube-router"
+)
+
+var k8sname string = DEFAULT_K8S_NAME
 
+// createK8sCluster creates the cluster named by the param name of its
+// step, with the params version and cni.
 func createK8sCluster(t *testing.T) {
+	k8sname = stepParam("name", DEFAULT_K8S_NAME)
 	t.Run("CreateK8sCluster", createK8s_3nodes)
 	t.Run("ValidateK8sCluster", validateK8sCluster)
 }
@@ -48,10 +56,10 @@ func createK8s_3nodes(t *testing.T) {
 		}
 	}
 	k8sRequest = pcc.K8sClusterRequest{
-		ID:         0,         //todo dynamic counter
-		Name:       k8sname,   //todo dynamic
-		K8sVersion: "v1.14.3", //todo dynamic
-		CniPlugin:  "kube-router",
+		ID:         0, //todo dynamic counter
+		Name:       k8sname,
+		K8sVersion: stepParam("version", DEFAULT_K8S_VERSION),
+		CniPlugin:  stepParam("cni", DEFAULT_K8S_CNI),
 		Nodes:      k8sNodes,
 		IgwPolicy:  "default",
 	}
@@ -76,9 +84,10 @@ func validateK8sCluster(t *testing.T) {
 		assert.Fatalf("Failed to find cluster %v: %v", k8sname, err)
 		return
 	}
+	timeline.Tag(id, pcc.TARGET_CLUSTER, k8sname)
 
-	timeout := time.After(45 * time.Minute)
-	tick := time.Tick(1 * time.Minute)
+	timeout := Pcc.Clock().After(45 * time.Minute)
+	tick := Pcc.Clock().After(1 * time.Minute)
 	done := false
 	var last_percent int8 = -1
 	for !done {
@@ -87,6 +96,7 @@ func validateK8sCluster(t *testing.T) {
 			assert.Fatalf("Timed out waiting for Kubernetes")
 			return
 		case <-tick:
+			tick = Pcc.Clock().After(1 * time.Minute)
 			status, percent, err := Pcc.GetKubernetesDeployStatus(id)
 			if err != nil {
 				assert.Fatalf("Failed to get deploy status "+
@@ -117,8 +127,8 @@ func validateK8sCluster(t *testing.T) {
 		}
 	}
 
-	timeout = time.After(5 * time.Minute)
-	tick = time.Tick(5 * time.Second)
+	timeout = Pcc.Clock().After(5 * time.Minute)
+	tick = Pcc.Clock().After(5 * time.Second)
 	done = false
 	for !done {
 		select {
@@ -126,6 +136,7 @@ func validateK8sCluster(t *testing.T) {
 			assert.Fatalf("health check timed out\n")
 			return
 		case <-tick:
+			tick = Pcc.Clock().After(5 * time.Second)
 			health, err := Pcc.GetKubernetesHealth(id)
 			if err != nil {
 				assert.Fatalf("Error geting K8s health\n")
@@ -167,8 +178,8 @@ func deleteAllK8sCluster(t *testing.T) {
 			}
 		}
 
-		timeout := time.After(10 * time.Minute)
-		tick := time.Tick(5 * time.Second)
+		timeout := Pcc.Clock().After(10 * time.Minute)
+		tick := Pcc.Clock().After(5 * time.Second)
 		var last_percent int8 = -1
 		for {
 			select {
@@ -176,9 +187,9 @@ func deleteAllK8sCluster(t *testing.T) {
 				assert.Fatalf("Time out deleting Kubernetes")
 				return
 			case <-tick:
+				tick = Pcc.Clock().After(5 * time.Second)
 				cluster, err := Pcc.GetKubernetesId(c.ID)
-				if err != nil && strings.Contains(err.Error(),
-					"doesn't exist") {
+				if pcc.IsNotFound(err) {
 					fmt.Printf("K8s delete OK\n")
 					return
 				}
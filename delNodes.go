@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
 	"github.com/platinasystems/test"
 )
 
@@ -35,8 +36,8 @@ func validateDeleteNodes(t *testing.T) {
 	var err error
 
 	// wait for node to be removed
-	time.Sleep(5 * time.Second)
-	start := time.Now()
+	Pcc.Sleep(5 * time.Second)
+	start := Pcc.Clock().Now()
 	done := false
 	timeout := 300 * time.Second
 	for !done {
@@ -45,7 +46,7 @@ func validateDeleteNodes(t *testing.T) {
 			done = false
 			err = Pcc.GetNodeSummary(id, node)
 			if err != nil {
-				if err.Error() == "no such node" {
+				if pcc.IsNotFound(err) {
 					fmt.Printf("%v deleted\n", node.Name)
 					delete(Nodes, id)
 					if len(Nodes) == 0 {
@@ -55,9 +56,9 @@ func validateDeleteNodes(t *testing.T) {
 			}
 		}
 		if !done {
-			time.Sleep(5 * time.Second)
+			Pcc.Sleep(5 * time.Second)
 		}
-		if time.Since(start) > timeout {
+		if Pcc.Since(start) > timeout {
 			fmt.Printf("delAllNodes timeout\n")
 			break
 		}
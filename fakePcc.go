@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+	"github.com/platinasystems/pcc-blackbox/lib/pcctest"
+)
+
+var fakePcc *pcctest.Server
+var fakeDocker *pcctest.DockerAPI
+
+// startFakePcc serves an in-memory PCC on Env.PccIp, or at
+// PccClient.BaseURL if set, seeded with the interfaces of the invaders
+// and servers in the environment, so the suites can run without an
+// appliance.
+func startFakePcc() (err error) {
+	if Env.PccIp == "" {
+		Env.PccIp = "127.0.0.1"
+	}
+	if Env.PccClient.BaseURL != "" {
+		fakePcc, err = pcctest.NewServerURL(Env.PccClient.BaseURL)
+	} else {
+		fakePcc, err = pcctest.NewServerAt(fmt.Sprintf("%v:%v",
+			Env.PccIp, pcc.DEFAULT_PCC_PORT))
+	}
+	if err != nil {
+		return
+	}
+	for _, i := range Env.Invaders {
+		seedFakeNode(i.node)
+	}
+	for _, s := range Env.Servers {
+		seedFakeNode(s.node)
+	}
+	// trust the self-signed certificate of the fake, made for this run
+	// so no CA or pin of the environment can match it, unless told not
+	// to check
+	t := &Env.PccClient.TLS
+	if fakePcc.TLS != nil && !t.Insecure {
+		t.CAFile = ""
+		t.Fingerprint = pcc.Fingerprint(fakePcc.Certificate().Raw)
+	}
+	fmt.Printf("Fake PCC listening on %v\n", fakePcc.URL)
+
+	// the containers of PCC, for the stats read from the Engine API
+	ds := &Env.DockerStats
+	if ds.Source == pcc.DOCKER_SOURCE_API && ds.Host == "" {
+		fakeDocker = pcctest.NewDockerAPI()
+		for i, name := range pcctest.PCC_CONTAINERS {
+			fakeDocker.SetContainer(pcctest.Container{
+				Name:        name,
+				CPU:         float64(5 * (i + 1)),
+				Memory:      uint64(100*(i+1)) << 20,
+				MemoryLimit: 8 << 30,
+				PIDs:        10 * (i + 1),
+			})
+		}
+		ds.Host = fakeDocker.Host()
+		fmt.Printf("Fake Docker API listening on %v\n", ds.Host)
+	}
+	return
+}
+
+func seedFakeNode(n node) {
+	for i, intf := range n.NetInterfaces {
+		name := intf.Name
+		if name == "" {
+			name = fmt.Sprintf("eth%d", i)
+		}
+		fakePcc.AddInterface(n.HostIp, name, intf.MacAddr,
+			intf.IsManagement)
+	}
+}
+
+func stopFakePcc() {
+	if fakePcc != nil {
+		fakePcc.Close()
+	}
+	if fakeDocker != nil {
+		fakeDocker.Close()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+	"github.com/platinasystems/pcc-blackbox/lib/pcctest"
+	"github.com/platinasystems/test"
+)
+
+// TestFakeNodes runs the nodes plan end to end against a fake PCC of its
+// own, seeded with the nodes of the environment, and checks that they
+// were all added and came online.
+func TestFakeNodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs for minutes")
+	}
+	test.SkipIfDryRun(t)
+	if len(Env.Invaders)+len(Env.Servers) == 0 {
+		t.Skip("no nodes in the environment")
+	}
+
+	oldFake, oldPcc := fakePcc, Pcc
+	oldNodes, oldByIP, oldKeys := Nodes, NodebyHostIP, SecurityKeys
+	defer func() {
+		fakePcc, Pcc = oldFake, oldPcc
+		Nodes, NodebyHostIP, SecurityKeys = oldNodes, oldByIP, oldKeys
+	}()
+
+	fakePcc = pcctest.NewServer()
+	defer fakePcc.Close()
+	var hosts []string
+	for _, i := range Env.Invaders {
+		seedFakeNode(i.node)
+		hosts = append(hosts, i.HostIp)
+	}
+	for _, s := range Env.Servers {
+		seedFakeNode(s.node)
+		hosts = append(hosts, s.HostIp)
+	}
+	var config pcc.PccClientConfig
+	config.TLS.Fingerprint = pcc.Fingerprint(fakePcc.Certificate().Raw)
+	p, err := pcc.AuthenticateURL(fakePcc.URL, pcc.Credential{
+		UserName: pcctest.DEFAULT_USER,
+		Password: pcctest.DEFAULT_PASSWORD,
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Pcc = p
+	Nodes = make(map[uint64]*pcc.NodeWithKubernetes)
+	NodebyHostIP = make(map[string]uint64)
+	SecurityKeys = make(map[string]*pcc.SecurityKey)
+
+	runPlan(t, plans["nodes"])
+
+	for _, host := range hosts {
+		node := Nodes[NodebyHostIP[host]]
+		if node == nil {
+			t.Errorf("%v not added", host)
+			continue
+		}
+		if err = Pcc.GetNodeSummary(node.Id, node); err != nil {
+			t.Errorf("%v: %v", host, err)
+			continue
+		}
+		connection, _ := Pcc.GetNodeConnectionStatus(node)
+		if connection != "online" {
+			t.Errorf("%v is %q, not online", host, connection)
+		}
+	}
+}
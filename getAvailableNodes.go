@@ -14,19 +14,24 @@ func getNodes(t *testing.T) {
 func getAvailableNodes(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
-	var (
-		err   error
-		nodes []*pcc.NodeWithKubernetes
-	)
 
-	nodes, err = Pcc.GetNodesWithKubernetes()
-	if err != nil {
+	if err := loadNodes(); err != nil {
 		assert.Fatalf("Error geting nodes: %v\n", err)
 		return
 	}
+}
+
+// loadNodes adds the nodes of PCC to Nodes and NodebyHostIP.
+func loadNodes() (err error) {
+	var nodes []*pcc.NodeWithKubernetes
 
+	if nodes, err = Pcc.GetNodesWithKubernetes(); err != nil {
+		return
+	}
 	for i := 0; i < len(nodes); i++ {
 		Nodes[nodes[i].Id] = nodes[i]
 		NodebyHostIP[nodes[i].Host] = nodes[i].Id
+		tagNode(nodes[i])
 	}
+	return
 }
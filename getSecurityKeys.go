@@ -16,22 +16,25 @@ func getSecKeys(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	var (
-		secKeys []pcc.SecurityKey
-		err     error
-	)
-
-	secKeys, err = Pcc.GetSecurityKeys()
-	if err != nil {
+	if err := loadSecurityKeys(); err != nil {
 		assert.Fatalf("Error in retrieving Security Keys: %v\n", err)
 		return
 	}
+}
 
+// loadSecurityKeys adds the security keys of PCC to SecurityKeys.
+func loadSecurityKeys() (err error) {
+	var secKeys []pcc.SecurityKey
+
+	if secKeys, err = Pcc.GetSecurityKeys(); err != nil {
+		return
+	}
 	for i := 0; i < len(secKeys); i++ {
 		SecurityKeys[secKeys[i].Alias] = &secKeys[i]
 		fmt.Printf("Mapping SecurityKey[%v]:%d - %v\n",
 			secKeys[i].Alias, secKeys[i].Id, secKeys[i].Description)
 	}
+	return
 }
 
 func getFirstKey() (sKey pcc.SecurityKey, err error) {
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"testing"
-	"time"
 
 	pcc "github.com/platinasystems/pcc-blackbox/lib"
 	"github.com/platinasystems/test"
@@ -16,10 +15,7 @@ func updateNodes_installLLDP(t *testing.T) {
 func installLLDP(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
-	var (
-		err   error
-		check bool
-	)
+	var err error
 
 	var isLLDPInNodes = make(map[uint64]bool)
 	for id := range Nodes {
@@ -45,24 +41,24 @@ func installLLDP(t *testing.T) {
 		}
 	}
 
-	from := time.Now()
+	from := Pcc.Clock().Now()
 	//Check LLDP installation
+	var nodesToCheck []uint64
 	for id := range Nodes {
 		if !isLLDPInNodes[id] {
-			fmt.Printf("Checking LLDP installation for nodeId:"+
-				"%v\n", id)
-
-			check, err = checkGenericInstallation(id, LLDP_TIMEOUT,
-				LLDP_NOTIFICATION, from)
-			if err != nil {
-				assert.Fatalf("Failed checking LLDP on %v "+
-					": %v", id, err)
-				return
-			}
-			if check {
-				fmt.Printf("LLDP correctly installed on "+
-					"nodeId:%v\n", id)
-			}
+			nodesToCheck = append(nodesToCheck, id)
+		}
+	}
+	fmt.Printf("Checking LLDP installation for nodeIds:%v\n", nodesToCheck)
+	r, err := checkInstallations(nodesToCheck, pcc.LLDP_INSTALL_EVENT, from)
+	if err != nil {
+		if len(r) == 0 {
+			assert.Fatalf("Failed checking LLDP: %v", err)
+		}
+		for _, nr := range r.Failed() {
+			t.Errorf("Failed checking LLDP on %v : %v",
+				nr.NodeId, nr.Err)
 		}
+		return
 	}
 }
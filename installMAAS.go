@@ -21,7 +21,7 @@ func installMAAS(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	from = time.Now()
+	from = Pcc.Clock().Now()
 	var isMAASInNodes = make(map[uint64]bool)
 	for _, i := range Env.Invaders {
 		var (
@@ -55,25 +55,23 @@ func verifyMAAS(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	var (
-		err   error
-		check bool
-	)
-
 	//Check MAAS installation
 	for i := 0; i < len(nodesToCheck); i++ {
 		id := nodesToCheck[i]
 		fmt.Printf("Checking MAAS installation for nodeId:%v\n", id)
 
-		check, err = checkMAASInstallation(id, from)
+		seq, err := Pcc.Catalog().MaasInstallSequence(id)
+		if err != nil {
+			assert.Fatalf("%v", err)
+			return
+		}
+		r, err := Pcc.WaitForSequence(from, seq)
+		fmt.Println(r.String())
 		if err != nil {
 			assert.Fatalf("Failed checking MaaS on %v"+
 				": %v", id, err)
 			return
 		}
-		if check {
-			fmt.Printf("MAAS correctly installed on nodeId:%v\n",
-				id)
-		}
+		fmt.Printf("MAAS correctly installed on nodeId:%v\n", id)
 	}
 }
@@ -0,0 +1,142 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// APIError is returned when PCC answers a request with a failure.
+// Status is the status PCC replied with, Message and Err the "message"
+// and "error" fields of the reply.
+type APIError struct {
+	Status    int
+	Method    string
+	Endpoint  string
+	Message   string
+	Err       string
+	RequestId string
+
+	// Message or Err were sent by PCC, in the body or the headers,
+	// rather than being the body of a reply from something else
+	fromPcc bool
+}
+
+func (e *APIError) Error() string {
+	s := fmt.Sprintf("%v %v: %v %v", e.Method, e.Endpoint, e.Status,
+		http.StatusText(e.Status))
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.Err != "" && e.Err != e.Message {
+		s += ": " + e.Err
+	}
+	if e.RequestId != "" {
+		s += fmt.Sprintf(" [request %v]", e.RequestId)
+	}
+	return s
+}
+
+// PCC often reports missing or duplicate records with a 400 and only
+// says so in the text of the reply.
+var (
+	notFoundText = []string{"not found", "no such", "doesn't exist",
+		"does not exist"}
+	conflictText = []string{"already exist", "duplicate"}
+)
+
+// says tells whether the message or error of e contains one of text.
+// The bare reason phrase of the status says nothing about the record.
+func (e *APIError) says(text []string) bool {
+	for _, field := range []string{e.Message, e.Err} {
+		if strings.EqualFold(strings.TrimSpace(field),
+			http.StatusText(e.Status)) {
+			continue
+		}
+		field = strings.ToLower(field)
+		for _, t := range text {
+			if strings.Contains(field, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AsAPIError returns the APIError err is or wraps, if any.
+func AsAPIError(err error) (e *APIError, ok bool) {
+	ok = errors.As(err, &e)
+	return
+}
+
+// IsNotFound tells whether err reports a missing record.  A 404 alone
+// doesn't: it is also what a proxy or an unknown route answers, so PCC
+// has to name the record in its reply.
+func IsNotFound(err error) bool {
+	e, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	if e.Status == http.StatusNotFound && !e.fromPcc {
+		return false
+	}
+	return e.says(notFoundText)
+}
+
+// IsConflict tells whether err reports a record that already exists or
+// a change that clashes with the current state.
+func IsConflict(err error) bool {
+	e, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	return e.Status == http.StatusConflict || e.says(conflictText)
+}
+
+// IsUnauthorized tells whether err reports a rejected credential.
+func IsUnauthorized(err error) bool {
+	e, ok := AsAPIError(err)
+	return ok && e.Status == http.StatusUnauthorized
+}
+
+// newAPIError builds the error for the reply r to op on endPoint.  The
+// message and error are taken from the JSON body when PCC sent one,
+// else from the headers, else the body is the error.
+func newAPIError(op string, endPoint string, r *http.Response,
+	body []byte) *APIError {
+
+	e := &APIError{
+		Status:    r.StatusCode,
+		Method:    op,
+		Endpoint:  endPoint,
+		Message:   r.Header.Get("Message"),
+		Err:       r.Header.Get("Error"),
+		RequestId: r.Header.Get(REQUEST_ID_HEADER),
+	}
+	e.fromPcc = e.Message != "" || e.Err != ""
+
+	var rg respGeneric
+	if json.Unmarshal(body, &rg) == nil {
+		if rg.Status != 0 {
+			e.Status = rg.Status
+		}
+		if rg.Message != "" {
+			e.Message = rg.Message
+		}
+		if rg.Error != "" {
+			e.Err = rg.Error
+		}
+		e.fromPcc = e.fromPcc || rg.Message != "" || rg.Error != ""
+	} else if e.Message == "" && e.Err == "" {
+		e.Err = strings.TrimSpace(string(body))
+	}
+	return e
+}
@@ -0,0 +1,49 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestIsNotFound(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		want   bool
+	}{
+		{"record", 404, nil,
+			`{"status":404,"message":"not found",` +
+				`"error":"node 12 not found"}`, true},
+		{"record in a 400", 400, nil,
+			`{"status":400,"error":"cluster 3 doesn't exist"}`, true},
+		{"record in the headers", 404,
+			http.Header{"Error": {"no such key"}}, "", true},
+		{"plain text 400", 400, nil, "record not found", true},
+		{"unknown route", 404, nil, "404 page not found", false},
+		{"proxy", 404, nil, "<html><body>Not Found</body></html>", false},
+		{"status text only", 404, nil,
+			`{"status":404,"error":"Not Found",` +
+				`"message":"No message available"}`, false},
+		{"empty", 404, nil, "", false},
+		{"other failure", 400, nil,
+			`{"status":400,"error":"invalid name"}`, false},
+	} {
+		r := &http.Response{StatusCode: tc.status, Header: tc.header}
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		err := fmt.Errorf("wrapped: %w",
+			newAPIError("GET", "pccserver/node/12", r, []byte(tc.body)))
+		if got := IsNotFound(err); got != tc.want {
+			t.Errorf("%v: IsNotFound(%v) = %v, want %v", tc.name, err,
+				got, tc.want)
+		}
+	}
+}
@@ -19,7 +19,6 @@ func (p *PccClient) GetApps(nodeId uint64) (apps []ProvisionedApp, err error) {
 
 	var (
 		endpoint string
-		body     []byte
 		resp     HttpResp
 	)
 
@@ -27,10 +26,6 @@ func (p *PccClient) GetApps(nodeId uint64) (apps []ProvisionedApp, err error) {
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("Get node apps failed\n%v\n", string(body))
-		return
-	}
 	if err = json.Unmarshal(resp.Data, &apps); err != nil {
 		return
 	}
@@ -45,7 +40,8 @@ func (p *PccClient) IsAppInstalled(nodeId uint64, appId string) (isInstalled boo
 	)
 
 	if apps, err = p.GetApps(nodeId); err != nil {
-		fmt.Printf("Failed to GetApps: %v\n", err)
+		p.logf(LOG_ERROR, Fields{"node": nodeId},
+			"Failed to GetApps: %v", err)
 		return
 	}
 
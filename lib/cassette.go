@@ -0,0 +1,375 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CASSETTE_RECORD = "record"
+	CASSETTE_REPLAY = "replay"
+	REDACTED        = "REDACTED"
+)
+
+// CassetteConfig makes a PccClient record every request and reply to
+// File, one JSON interaction per line, or replay them from File instead
+// of talking to PCC.  Tokens, passwords and other secrets are redacted
+// before they are written.  PccClient.Close closes the recording.
+type CassetteConfig struct {
+	Mode string
+	File string
+}
+
+// Interaction is a request sent to PCC and the reply it got, as kept in
+// a cassette.  Time is when the reply was received.
+type Interaction struct {
+	Time         time.Time `json:",omitempty"`
+	Method       string
+	Endpoint     string
+	RequestBody  json.RawMessage `json:",omitempty"`
+	Status       int
+	Headers      map[string]string `json:",omitempty"`
+	ResponseBody json.RawMessage   `json:",omitempty"`
+	ResponseText string            `json:",omitempty"`
+}
+
+// ErrNotRecorded is returned in replay mode for a request the cassette
+// has no reply for.
+var ErrNotRecorded = errors.New("no reply left in cassette")
+
+// reply headers worth keeping, the others are left out of the cassette
+var cassetteHeaders = []string{"Content-Type", "Message", "Error",
+	REQUEST_ID_HEADER}
+
+var secretKey = regexp.MustCompile(`(?i)password|passwd|token|secret|` +
+	`bearer|authorization|private`)
+
+// secret looking parts of a text or form body: a private key, a bearer
+// token, and the value of a secret looking key, e.g. "token=..." or
+// "Password: ..."
+var (
+	privateKeyText = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE ` +
+		`KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+	bearerText = regexp.MustCompile(`(?i)\bbearer\s+[^\s"',;&]+`)
+	secretText = regexp.MustCompile(`(?i)([\w.-]*(?:password|passwd|` +
+		`token|secret|authorization|private)[\w.-]*["']?\s*[=:]\s*` +
+		`["']?)[^\s"',;&]+`)
+)
+
+// redactText replaces the secret looking parts of a body that isn't
+// JSON.
+func redactText(body string) string {
+	body = privateKeyText.ReplaceAllString(body, REDACTED)
+	body = bearerText.ReplaceAllString(body, "Bearer "+REDACTED)
+	return secretText.ReplaceAllString(body, "${1}"+REDACTED)
+}
+
+// redact replaces the values of the secret looking keys of a JSON
+// document.  Anything that isn't JSON, e.g. an uploaded key, is dropped.
+func redact(body []byte) json.RawMessage {
+	var v interface{}
+
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &v) != nil {
+		return nil
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, x := range t {
+			if secretKey.MatchString(k) {
+				t[k] = REDACTED
+			} else {
+				t[k] = redactValue(x)
+			}
+		}
+	case []interface{}:
+		for i, x := range t {
+			t[i] = redactValue(x)
+		}
+	}
+	return v
+}
+
+// query parameters that change from one run to the next, e.g. the time
+// notifications are read from, and are left out when matching a request
+// with its reply
+var volatileParams = []string{"from", "page", "beforeId"}
+
+func endpointOf(r *http.Request) string {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/")
+	if r.URL.RawQuery != "" {
+		endpoint += "?" + r.URL.RawQuery
+	}
+	return endpoint
+}
+
+// replayKey is what a request is matched on with a reply of the
+// cassette: its method, and its endpoint without the volatile query
+// parameters.  Replies for the pages of a query are given out in the
+// order they were recorded in.
+func replayKey(method string, endpoint string) string {
+	i := strings.Index(endpoint, "?")
+	if i < 0 {
+		return method + " " + endpoint
+	}
+	q, err := url.ParseQuery(endpoint[i+1:])
+	if err != nil {
+		return method + " " + endpoint
+	}
+	for _, param := range volatileParams {
+		q.Del(param)
+	}
+	key := method + " " + endpoint[:i]
+	if len(q) > 0 {
+		key += "?" + q.Encode()
+	}
+	return key
+}
+
+func newCassetteTransport(config CassetteConfig,
+	next http.RoundTripper) (rt http.RoundTripper, err error) {
+
+	switch config.Mode {
+	case "":
+		rt = next
+	case CASSETTE_RECORD:
+		var f *os.File
+
+		if f, err = os.Create(config.File); err != nil {
+			return
+		}
+		rt = &recorder{next: next, file: f}
+	case CASSETTE_REPLAY:
+		rt, err = loadCassette(config.File)
+	default:
+		err = fmt.Errorf("invalid cassette mode %v", config.Mode)
+	}
+	return
+}
+
+// recorder sends the requests to PCC and appends each interaction to
+// the cassette as soon as it completes, so that an aborted run still
+// leaves a usable cassette behind.
+type recorder struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (rec *recorder) RoundTrip(r *http.Request) (resp *http.Response,
+	err error) {
+
+	var reqBody, respBody []byte
+
+	if r.GetBody != nil {
+		body, errBody := r.GetBody()
+		if errBody == nil {
+			reqBody, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+	if resp, err = rec.next.RoundTrip(r); err != nil {
+		return
+	}
+	respBody, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := Interaction{
+		Time:        time.Now(),
+		Method:      r.Method,
+		Endpoint:    endpointOf(r),
+		RequestBody: redact(reqBody),
+		Status:      resp.StatusCode,
+		Headers:     make(map[string]string),
+	}
+	if i.ResponseBody = redact(respBody); i.ResponseBody == nil {
+		i.ResponseText = redactText(string(respBody))
+	}
+	for _, h := range cassetteHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			i.Headers[h] = v
+		}
+	}
+
+	line, err := json.Marshal(i)
+	if err == nil {
+		rec.mu.Lock()
+		_, err = rec.file.Write(append(line, '\n'))
+		rec.mu.Unlock()
+	}
+	if err != nil {
+		resp = nil
+	}
+	return
+}
+
+// Close writes the cassette to disk and closes it.
+func (rec *recorder) Close() (err error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	err = rec.file.Sync()
+	if cerr := rec.file.Close(); err == nil {
+		err = cerr
+	}
+	return
+}
+
+// Close closes the cassette p records to, if any, once done with p and
+// its copies.
+func (p *PccClient) Close() (err error) {
+	if c, ok := p.client.Transport.(io.Closer); ok {
+		err = c.Close()
+	}
+	return
+}
+
+// replaying tells whether p answers from a cassette.
+func (p *PccClient) replaying() bool {
+	_, ok := p.clock.(*player)
+	return ok
+}
+
+// player answers the requests from a cassette.  Each request gets the
+// next reply recorded for the same method and endpoint, so polling
+// loops see the same sequence of states as during the recording.
+//
+// It is also the clock of the client: its time is that of the recording,
+// when the last reply given out was received, moved forward by the
+// waits of the client, which end at once.  The waits so look for the
+// notifications raised since a time taken as during the recording, not
+// for ones raised after the run started, and a replay doesn't wait for
+// the retries and polls of the recording.
+type player struct {
+	mu      sync.Mutex
+	replies map[string][]Interaction
+	now     time.Time
+}
+
+func loadCassette(file string) (pl *player, err error) {
+	var f *os.File
+
+	if f, err = os.Open(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	pl = &player{replies: make(map[string][]Interaction)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for n := 1; scanner.Scan(); n++ {
+		var i Interaction
+
+		if err = json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			err = fmt.Errorf("%v:%v: %v", file, n, err)
+			return
+		}
+		key := replayKey(i.Method, i.Endpoint)
+		pl.replies[key] = append(pl.replies[key], i)
+		if pl.now.IsZero() {
+			pl.now = i.Time
+		}
+	}
+	if pl.now.IsZero() {
+		// recorded without the time
+		pl.now = time.Now()
+	}
+	err = scanner.Err()
+	return
+}
+
+func (pl *player) RoundTrip(r *http.Request) (resp *http.Response,
+	err error) {
+
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	key := replayKey(r.Method, endpointOf(r))
+
+	pl.mu.Lock()
+	replies := pl.replies[key]
+	if len(replies) == 0 {
+		pl.mu.Unlock()
+		err = fmt.Errorf("%w for %v", ErrNotRecorded, key)
+		return
+	}
+	i := replies[0]
+	// the last reply is repeated for as long as it is asked for
+	if len(replies) > 1 {
+		pl.replies[key] = replies[1:]
+	} else {
+		// a repeated reply doesn't take the clock back
+		replies[0].Time = time.Time{}
+	}
+	if !i.Time.IsZero() {
+		pl.now = i.Time
+	}
+	pl.mu.Unlock()
+
+	body := []byte(i.ResponseText)
+	if i.ResponseBody != nil {
+		body = i.ResponseBody
+	}
+	resp = &http.Response{
+		Status: fmt.Sprintf("%d %s", i.Status,
+			http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+	for k, v := range i.Headers {
+		resp.Header.Set(k, v)
+	}
+	return
+}
+
+// Now returns the time of the recording, or from the time the cassette
+// was loaded for one recorded without.
+func (pl *player) Now() time.Time {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.now
+}
+
+// After moves the clock d forward and fires at once.
+func (pl *player) After(d time.Duration) <-chan time.Time {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if d > 0 {
+		pl.now = pl.now.Add(d)
+	}
+	c := make(chan time.Time, 1)
+	c <- pl.now
+	return c
+}
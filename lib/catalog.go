@@ -0,0 +1,464 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Workflows of the catalogs
+const (
+	AGENT_INSTALL_EVENT     = "agentInstall"
+	COLLECTOR_INSTALL_EVENT = "collectorInstall"
+	LLDP_INSTALL_EVENT      = "lldpInstall"
+	MAAS_INSTALL_EVENT      = "maasInstall"
+	PORTUS_INSTALL_EVENT    = "portusInstall"
+	PXEBOOT_NODE_ADD_EVENT  = "pxebootNodeAdd"
+
+	// a message starting with it is a regular expression, else it
+	// only has to be contained in the notification
+	REGEX_PREFIX = "re:"
+
+	DEFAULT_CATALOG_DIR = "catalogs"
+)
+
+// CatalogConfig selects the catalog of Release in Dir, DEFAULT_CATALOG_DIR
+// by default.  No Release keeps DefaultCatalog.
+type CatalogConfig struct {
+	Dir     string
+	Release string
+}
+
+// NamedMessage is a milestone of a workflow.
+type NamedMessage struct {
+	Name    string
+	Message string
+}
+
+// WorkflowMessages are the messages PCC notifies during a workflow:
+// Success and Failure end it, Intermediate are only logged.  Milestones,
+// if any, are notified in that order.  ProvisionFailure are the provision
+// statuses of a node that fail it.  Messages may hold the {name} and
+// {cluster} placeholders, e.g. for the pool and cluster of a Ceph pool.
+// Timeout is in seconds.
+type WorkflowMessages struct {
+	Timeout          uint16
+	Success          []string
+	Failure          []string       `json:",omitempty" yaml:",omitempty"`
+	Intermediate     []string       `json:",omitempty" yaml:",omitempty"`
+	Milestones       []NamedMessage `json:",omitempty" yaml:",omitempty"`
+	ProvisionFailure []string       `json:",omitempty" yaml:"provisionFailure,omitempty"`
+}
+
+// Catalog holds the messages of the workflows as of a PCC release.
+type Catalog struct {
+	Release   string
+	Workflows map[string]WorkflowMessages
+}
+
+func cephWorkflows() map[string]WorkflowMessages {
+	name, cluster := "{name}", "{cluster}"
+	return map[string]WorkflowMessages{
+		CEPH_CLUSTER_INSTALL_EVENT: {
+			Timeout: CEPH_3_NODE_INSTALLATION_TIMEOUT,
+			Success: []string{CEPH_INSTALLATION_SUCCESS_NOTIFICATION},
+			Failure: []string{
+				fmt.Sprintf(CEPH_INSTALLATION_FAILED_NOTIFICATION_1, cluster),
+				CEPH_INSTALLATION_FAILED_NOTIFICATION_2,
+				CEPH_INSTALLATION_FAILED_NOTIFICATION_3,
+				fmt.Sprintf(CEPH_INSTALLATION_FAILED_NOTIFICATION_4, cluster),
+			},
+			Intermediate: []string{
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_1,
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_2,
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_3,
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_4,
+				fmt.Sprintf(CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_5, cluster),
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_6,
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_7,
+				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_8,
+			},
+			// drive provisioning is skipped when there are no
+			// unused drives
+			Milestones: []NamedMessage{
+				{"installation begins", CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_1},
+				{"network created", CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_2},
+				{"cluster deployed", CEPH_INSTALLATION_SUCCESS_NOTIFICATION},
+			},
+		},
+		CEPH_CLUSTER_UNINSTALL_EVENT: {
+			Timeout: CEPH_3_NODE_UNINSTALLATION_TIMEOUT,
+			Success: []string{CEPH_UNINSTALLATION_SUCCESS_NOTIFICATION},
+			Failure: []string{
+				fmt.Sprintf(CEPH_UNINSTALLATION_FAILED_NOTIFICATION_1, cluster),
+				fmt.Sprintf(CEPH_UNINSTALLATION_FAILED_NOTIFICATION_2, cluster),
+			},
+			Intermediate: []string{
+				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_1,
+				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_2,
+				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_3,
+				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_4,
+			},
+		},
+		CEPH_POOL_CREATE_EVENT: {
+			Timeout: CEPH_POOL_CREATION_TIMEOUT,
+			Success: []string{fmt.Sprintf(CEPH_POOL_CREATION_SUCCESS_NOTIFICATION, name, cluster)},
+			Failure: []string{fmt.Sprintf(CEPH_POOL_CREATION_FAILED_NOTIFICATION, name, cluster)},
+			Intermediate: []string{
+				fmt.Sprintf(CEPH_POOL_CREATION_INTERMEDIATE_NOTIFICATION_1, name, cluster),
+			},
+		},
+		CEPH_POOL_DELETE_EVENT: {
+			Timeout: CEPH_POOL_DELETION_TIMEOUT,
+			Success: []string{fmt.Sprintf(CEPH_POOL_DELETION_SUCCESS_NOTIFICATION, name)},
+			Failure: []string{fmt.Sprintf(CEPH_POOL_DELETION_FAILED_NOTIFICATION, name)},
+			Intermediate: []string{
+				fmt.Sprintf(CEPH_POOL_DELETION_INTERMEDIATE_NOTIFICATION_1, name, cluster),
+			},
+		},
+		CEPH_FS_CREATE_EVENT: {
+			Timeout: CEPH_FS_CREATION_TIMEOUT,
+			Success: []string{fmt.Sprintf(CEPH_FS_CREATION_SUCCESS_NOTIFICATION, name, cluster)},
+			Failure: []string{
+				fmt.Sprintf(CEPH_FS_CREATION_FAILED_NOTIFICATION_1, name, cluster),
+				fmt.Sprintf(CEPH_FS_CREATION_FAILED_NOTIFICATION_2, name, cluster),
+			},
+			Intermediate: []string{
+				fmt.Sprintf(CEPH_FS_CREATION_INTERMEDIATE_NOTIFICATION_1, name, cluster),
+			},
+		},
+		CEPH_FS_DELETE_EVENT: {
+			Timeout: CEPH_FS_DELETION_TIMEOUT,
+			Success: []string{fmt.Sprintf(CEPH_FS_DELETION_SUCCESS_NOTIFICATION, name)},
+			Failure: []string{
+				fmt.Sprintf(CEPH_FS_DELETION_FAILED_NOTIFICATION_1, name),
+				fmt.Sprintf(CEPH_FS_DELETION_FAILED_NOTIFICATION_2, name),
+			},
+			Intermediate: []string{
+				fmt.Sprintf(CEPH_FS_DELETION_INTERMEDIATE_NOTIFICATION_1, name),
+			},
+		},
+	}
+}
+
+// DefaultCatalog holds the messages known to lib.  The catalogs loaded
+// from files only need the workflows that differ from it.
+var DefaultCatalog = newDefaultCatalog()
+
+//go:generate go run gen_catalog.go
+
+// defaultCatalogHeader heads catalogs/default.yaml.
+const defaultCatalogHeader = `# Messages PCC notifies for each workflow, as known to lib.  Copy this
+# file to <release>.yaml, reword what changed in that PCC release and set
+# PccClient.Catalog.Release in testEnv.json to use it.  Workflows left out
+# of a catalog keep these messages.
+#
+# A message matches the notifications containing it, or is a regular
+# expression when it starts with "re:".  {name} and {cluster} stand for
+# the Ceph pool, FS or cluster being checked.  timeout is in seconds.
+# provisionFailure are the provision statuses of the node that fail the
+# workflow as soon as they are seen.
+`
+
+// DefaultCatalogYAML returns DefaultCatalog as catalogs/default.yaml has
+// it, the file being written from it by go generate.
+func DefaultCatalogYAML() (b []byte, err error) {
+	if b, err = yaml.Marshal(DefaultCatalog); err != nil {
+		return
+	}
+	b = append([]byte(defaultCatalogHeader), b...)
+	return
+}
+
+func newDefaultCatalog() *Catalog {
+	c := &Catalog{Release: "default", Workflows: cephWorkflows()}
+
+	addNodeFailure := []string{REGEX_PREFIX + `add node at \S* *failed`}
+	c.Workflows[AGENT_INSTALL_EVENT] = WorkflowMessages{
+		Timeout:          150,
+		Success:          []string{"The agent has been installed"},
+		Failure:          addNodeFailure,
+		ProvisionFailure: []string{PROVISION_STATUS_ADD_FAILED},
+	}
+	c.Workflows[COLLECTOR_INSTALL_EVENT] = WorkflowMessages{
+		Timeout:          150,
+		Success:          []string{"The collector has been installed"},
+		Failure:          addNodeFailure,
+		ProvisionFailure: []string{PROVISION_STATUS_ADD_FAILED},
+	}
+	c.Workflows[LLDP_INSTALL_EVENT] = WorkflowMessages{
+		Timeout:          300,
+		Success:          []string{"[LLDPD] Installed version"},
+		Failure:          []string{REGEX_PREFIX + `\[LLDPD\].*failed`},
+		ProvisionFailure: []string{PROVISION_STATUS_UPDATE_FAILED},
+	}
+	c.Workflows[PORTUS_INSTALL_EVENT] = WorkflowMessages{
+		Timeout:          400,
+		Success:          []string{"[Portus] has been installed correctly"},
+		Failure:          []string{REGEX_PREFIX + `\[Portus\].*failed`},
+		ProvisionFailure: []string{PROVISION_STATUS_UPDATE_FAILED},
+	}
+	c.Workflows[PXEBOOT_NODE_ADD_EVENT] = WorkflowMessages{
+		Timeout:          400,
+		Success:          []string{"new node added successfully"},
+		Failure:          addNodeFailure,
+		ProvisionFailure: []string{PROVISION_STATUS_ADD_FAILED},
+	}
+	maas := WorkflowMessages{
+		Timeout:          MAAS_INSTALL_TIMEOUT,
+		Milestones:       MAAS_INSTALL_MILESTONES,
+		ProvisionFailure: []string{PROVISION_STATUS_UPDATE_FAILED},
+	}
+	for i, m := range MAAS_INSTALL_MILESTONES {
+		if i == len(MAAS_INSTALL_MILESTONES)-1 {
+			maas.Success = []string{m.Message}
+		} else {
+			maas.Intermediate = append(maas.Intermediate, m.Message)
+		}
+	}
+	c.Workflows[MAAS_INSTALL_EVENT] = maas
+	return c
+}
+
+// Catalog returns the catalog selected for p, DefaultCatalog unless
+// PccClientConfig.Catalog names a release.
+func (p *PccClient) Catalog() *Catalog {
+	if p.catalog != nil {
+		return p.catalog
+	}
+	return DefaultCatalog
+}
+
+// LoadCatalog reads a catalog from a YAML (.yaml, .yml) or JSON file.
+// The workflows it lacks are taken from DefaultCatalog.
+func LoadCatalog(file string) (c *Catalog, err error) {
+	var data []byte
+
+	if data, err = ioutil.ReadFile(file); err != nil {
+		return
+	}
+	c = &Catalog{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	default:
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil {
+		err = fmt.Errorf("%v: %v", file, err)
+		return
+	}
+	for name, w := range c.Workflows {
+		if err = w.check(name); err != nil {
+			err = fmt.Errorf("%v: %v", file, err)
+			return
+		}
+	}
+	if c.Workflows == nil {
+		c.Workflows = make(map[string]WorkflowMessages)
+	}
+	for name, w := range DefaultCatalog.Workflows {
+		if _, found := c.Workflows[name]; !found {
+			c.Workflows[name] = w
+		}
+	}
+	return
+}
+
+// LoadReleaseCatalog loads the catalog of release from dir, in
+// <release>.yaml, <release>.yml or <release>.json.
+func LoadReleaseCatalog(dir string, release string) (c *Catalog, err error) {
+	if dir == "" {
+		dir = DEFAULT_CATALOG_DIR
+	}
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		file := filepath.Join(dir, release+ext)
+		if _, errStat := os.Stat(file); errStat == nil {
+			if c, err = LoadCatalog(file); err == nil &&
+				c.Release == "" {
+				c.Release = release
+			}
+			return
+		}
+	}
+	err = fmt.Errorf("no catalog for release %v in %v", release, dir)
+	return
+}
+
+// check tells whether w, the messages of workflow name, has a timeout and
+// compiles its regular expressions.
+func (w WorkflowMessages) check(name string) (err error) {
+	var all []string
+
+	if w.Timeout == 0 {
+		err = fmt.Errorf("workflow %v: no timeout", name)
+		return
+	}
+
+	all = append(all, w.Success...)
+	all = append(all, w.Failure...)
+	all = append(all, w.Intermediate...)
+	all = append(all, w.ProvisionFailure...)
+	for _, m := range w.Milestones {
+		all = append(all, m.Message)
+	}
+	for _, msg := range all {
+		if strings.HasPrefix(msg, REGEX_PREFIX) {
+			_, err = regexp.Compile(strings.TrimPrefix(msg,
+				REGEX_PREFIX))
+			if err != nil {
+				err = fmt.Errorf("workflow %v: %v", name, err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// Workflow returns the messages of workflow with their placeholders
+// replaced by vars, e.g. {"name": pool, "cluster": cluster}.
+func (c *Catalog) Workflow(workflow string, vars map[string]string) (
+	w WorkflowMessages, err error) {
+
+	w, found := c.Workflows[workflow]
+	if !found {
+		err = fmt.Errorf("no %v workflow in catalog %v", workflow,
+			c.Release)
+		return
+	}
+	w.Success = expandAll(w.Success, vars)
+	w.Failure = expandAll(w.Failure, vars)
+	w.Intermediate = expandAll(w.Intermediate, vars)
+	w.ProvisionFailure = expandAll(w.ProvisionFailure, vars)
+	milestones := w.Milestones
+	w.Milestones = nil
+	for _, m := range milestones {
+		m.Message = expand(m.Message, vars)
+		w.Milestones = append(w.Milestones, m)
+	}
+	return
+}
+
+// Waiter returns an EventWaiter for the outcome of workflow.
+func (c *Catalog) Waiter(workflow string, vars map[string]string) (
+	w EventWaiter, err error) {
+
+	var msgs WorkflowMessages
+
+	if msgs, err = c.Workflow(workflow, vars); err != nil {
+		return
+	}
+	w = EventWaiter{
+		Name:             workflow,
+		Success:          msgs.Success,
+		Failure:          msgs.Failure,
+		Progress:         msgs.Intermediate,
+		ProvisionFailure: msgs.ProvisionFailure,
+		Timeout:          time.Duration(msgs.Timeout) * time.Second,
+	}
+	return
+}
+
+// Sequence returns the milestones of workflow as a Sequence.
+func (c *Catalog) Sequence(workflow string, name string,
+	vars map[string]string) (s Sequence, err error) {
+
+	var msgs WorkflowMessages
+
+	if msgs, err = c.Workflow(workflow, vars); err != nil {
+		return
+	}
+	s = Sequence{
+		Name:             name,
+		Failure:          msgs.Failure,
+		ProvisionFailure: msgs.ProvisionFailure,
+		Timeout:          time.Duration(msgs.Timeout) * time.Second,
+	}
+	for _, m := range msgs.Milestones {
+		s.Milestones = append(s.Milestones,
+			Milestone{Name: m.Name, Message: m.Message})
+	}
+	return
+}
+
+// CatalogVerifier is the Verifier of a workflow of a catalog.
+type CatalogVerifier struct {
+	msgs WorkflowMessages
+}
+
+func (c *Catalog) Verifier(workflow string, vars map[string]string) (
+	v *CatalogVerifier, err error) {
+
+	v = &CatalogVerifier{}
+	v.msgs, err = c.Workflow(workflow, vars)
+	return
+}
+
+func (v *CatalogVerifier) GetTimeout() time.Duration {
+	return time.Duration(v.msgs.Timeout)
+}
+
+func (v *CatalogVerifier) GetEventsToCheck() EventsToCheck {
+	events := EventsToCheck{}
+	for _, msg := range v.msgs.Intermediate {
+		events[msg] = false
+	}
+	for _, msg := range append(v.msgs.Success, v.msgs.Failure...) {
+		events[msg] = true
+	}
+	return events
+}
+
+func (v *CatalogVerifier) GetFailures() []string {
+	return v.msgs.Failure
+}
+
+func expand(msg string, vars map[string]string) string {
+	regex := strings.HasPrefix(msg, REGEX_PREFIX)
+	for k, v := range vars {
+		if regex {
+			v = regexp.QuoteMeta(v)
+		}
+		msg = strings.Replace(msg, "{"+k+"}", v, -1)
+	}
+	return msg
+}
+
+func expandAll(msgs []string, vars map[string]string) (out []string) {
+	for _, msg := range msgs {
+		out = append(out, expand(msg, vars))
+	}
+	return
+}
+
+var regexCache sync.Map
+
+// matchMessage tells whether msg is matched by pattern, a regular
+// expression after REGEX_PREFIX or else a substring.
+func matchMessage(msg string, pattern string) bool {
+	if !strings.HasPrefix(pattern, REGEX_PREFIX) {
+		return strings.Contains(msg, pattern)
+	}
+	re, found := regexCache.Load(pattern)
+	if !found {
+		compiled, err := regexp.Compile(
+			strings.TrimPrefix(pattern, REGEX_PREFIX))
+		if err != nil {
+			return false
+		}
+		re, _ = regexCache.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(msg)
+}
@@ -0,0 +1,97 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"ok.yaml", `
+workflows:
+  lldpInstall:
+    timeout: 60
+    success:
+    - LLDP is up
+`, ""},
+		{"notimeout.yaml", `
+workflows:
+  lldpInstall:
+    success:
+    - LLDP is up
+`, "workflow lldpInstall: no timeout"},
+		{"badregex.yaml", `
+workflows:
+  portusInstall:
+    timeout: 60
+    success:
+    - re:[Portus
+`, "workflow portusInstall: error parsing regexp"},
+	} {
+		file := filepath.Join(dir, tc.name)
+		err := ioutil.WriteFile(file, []byte(tc.content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := LoadCatalog(file)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("%v: %v", tc.name, err)
+				continue
+			}
+			w := c.Workflows[LLDP_INSTALL_EVENT]
+			if w.Timeout != 60 {
+				t.Errorf("%v: timeout %v, want 60", tc.name,
+					w.Timeout)
+			}
+			if _, found := c.Workflows[MAAS_INSTALL_EVENT]; !found {
+				t.Errorf("%v: default workflows not kept", tc.name)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: error %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestDefaultCatalog(t *testing.T) {
+	for name, w := range DefaultCatalog.Workflows {
+		if err := w.check(name); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDefaultCatalogFile(t *testing.T) {
+	want, err := DefaultCatalogYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join("..", DEFAULT_CATALOG_DIR, "default.yaml")
+	got, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%v differs from DefaultCatalog, run go generate "+
+			"./lib", file)
+	}
+}
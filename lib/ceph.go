@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"time"
 	"github.com/platinasystems/tiles/pccserver/storage/ceph"
-	"strings"
 )
 
 type cephPoolTypes string
@@ -164,21 +163,17 @@ type CephNodes struct {
 
 func (p *PccClient) CreateCephCluster(request CreateCephClusterRequest) (id uint64, err error) {
 	var (
-		body              []byte
 		data              []byte
-		resp              HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/cluster")
 	if data, err = json.Marshal(request); err != nil {
 		err = fmt.Errorf("Invalid struct for ceph creation..ERROR: %v", err)
 	}else {
-		if resp, body, err = p.pccGateway("POST", endpoint, data); err != nil {
-			err = fmt.Errorf("%v\n%v\n", string(body), err)
-		} else {
-			if resp.Status != 200 {
-				err = fmt.Errorf("%v\n", string(body))
+		if _, _, err = p.pccGateway("POST", endpoint, data); err == nil {
+			if errSleep := p.Sleep(time.Second * 5); errSleep != nil {
+				err = errSleep
+				return
 			}
-			time.Sleep(time.Second * 5)
 			cluster, errGet := p.GetCephCluster(request.Name)
 			if errGet == nil {
 				if cluster != nil {
@@ -215,17 +210,12 @@ func (p *PccClient) GetCephCluster(clusterName string) (cluster *models.CephClus
 
 func (p *PccClient) GetAllCephClusters() (clusterList []*models.CephCluster, err error){
 	var (
-		body              []byte
 		data              []byte
 		resp              HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/cluster")
-	if resp, body, err = p.pccGateway("GET", endpoint, data); err != nil {
-		return nil, fmt.Errorf("%v\n%v\n", string(body), err)
-	}
-	if resp.Status != 200 {
-		fmt.Printf("Ceph status check failed:\n%v\n", string(body))
-		return nil, fmt.Errorf("%v\n", string(body))
+	if resp, _, err = p.pccGateway("GET", endpoint, data); err != nil {
+		return nil, err
 	}
 	err = json.Unmarshal(resp.Data, &clusterList)
 	if err != nil {
@@ -236,20 +226,15 @@ func (p *PccClient) GetAllCephClusters() (clusterList []*models.CephCluster, err
 
 func (p *PccClient) DeleteCephCluster(id uint64) (err error){
 	var (
-		body              []byte
 		data              []byte
-		resp              HttpResp
 	)
 	/*cluster, err := p.GetCephCluster(name)
 	if err != nil {
 		return err
 	}*/
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/cluster/%v", id)
-	if resp, body, err = p.pccGateway("DELETE", endpoint, data); err != nil {
-		return fmt.Errorf("%v\n%v\n", string(body), err)
-	}
-	if resp.Status != 200 {
-		return fmt.Errorf("%v\n", string(body))
+	if _, _, err = p.pccGateway("DELETE", endpoint, data); err != nil {
+		return err
 	}
 	return nil
 }
@@ -285,17 +270,11 @@ func (p *PccClient) GetCephPool(name string, cephClusterId uint64) (cephPool *mo
 func (p *PccClient) GetAllCephPools(cephClusterId uint64) (cephPools []*models.CephPool, err error) {
 	if cephClusterId != 0{
 		var (
-			body              []byte
 			data              []byte
 			resp              HttpResp
 		)
 		endpoint := fmt.Sprintf("pccserver/storage/ceph/cluster/%v/pools", cephClusterId)
-		if resp, body, err = p.pccGateway("GET", endpoint, data); err != nil {
-			err = fmt.Errorf("%v\n%v\n", string(body), err)
-		}else if resp.Status != 200 {
-			fmt.Printf("Ceph pools get list failed:\n%v\n", string(body))
-			err = fmt.Errorf("%v\n", string(body))
-		}else {
+		if resp, _, err = p.pccGateway("GET", endpoint, data); err == nil {
 			err = json.Unmarshal(resp.Data, &cephPools)
 			if err != nil {
 				err = fmt.Errorf("JSON unmarshal failed for status check..ERROR: %v", err)
@@ -313,22 +292,17 @@ func (p *PccClient) GetAvailableCephPools() (cephPools []*models.CephPool, err e
 
 func (p *PccClient) CreateCephPool(request CreateCephPoolRequest) (id uint64, err error) {
 	var (
-		body              []byte
 		data              []byte
-		resp              HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/pool")
 	if data, err = json.Marshal(request); err != nil {
 		err = fmt.Errorf("Invalid struct for ceph pool creation..ERROR: %v", err)
 	}else {
-		if resp, body, err = p.pccGateway("POST", endpoint, data); err != nil {
-			err = fmt.Errorf("%v\n%v\n", string(body), err)
-		} else {
-			if resp.Status != 200 {
-				fmt.Printf("Ceph Pool creation failed:\n%v\n", string(body))
-				err = fmt.Errorf("%v\n", string(body))
+		if _, _, err = p.pccGateway("POST", endpoint, data); err == nil {
+			if errSleep := p.Sleep(time.Second * 5); errSleep != nil {
+				err = errSleep
+				return
 			}
-			time.Sleep(time.Second * 5)
 			cephPool, errGet := p.GetCephPool(request.Name, request.CephClusterId)
 			if errGet == nil {
 				if cephPool != nil {
@@ -344,16 +318,11 @@ func (p *PccClient) CreateCephPool(request CreateCephPoolRequest) (id uint64, er
 
 func (p *PccClient) DeleteCephPool(id uint64) (err error) {
 	var (
-		body              []byte
 		data              []byte
-		resp              HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/pool/%v", id)
-	if resp, body, err = p.pccGateway("DELETE", endpoint, data); err != nil {
-		return fmt.Errorf("%v\n%v\n", string(body), err)
-	}
-	if resp.Status != 200 {
-		return fmt.Errorf("%v\n", string(body))
+	if _, _, err = p.pccGateway("DELETE", endpoint, data); err != nil {
+		return err
 	}
 	return nil
 }
@@ -388,17 +357,11 @@ func (p *PccClient) GetCephFS(name string, cephClusterId uint64) (cephFS *models
 func (p *PccClient) GetAllCephFS(cephClusterId uint64) (cephFSList []*models.CephFS, err error) {
 	if cephClusterId != 0{
 		var (
-			body              []byte
 			data              []byte
 			resp              HttpResp
 		)
 		endpoint := fmt.Sprintf("pccserver/storage/ceph/cluster/%v/fs", cephClusterId)
-		if resp, body, err = p.pccGateway("GET", endpoint, data); err != nil {
-			err = fmt.Errorf("%v\n%v\n", string(body), err)
-		}else if resp.Status != 200 {
-			fmt.Printf("Ceph FS get list failed:\n%v\n", string(body))
-			err = fmt.Errorf("%v\n", string(body))
-		}else {
+		if resp, _, err = p.pccGateway("GET", endpoint, data); err == nil {
 			err = json.Unmarshal(resp.Data, &cephFSList)
 			if err != nil {
 				err = fmt.Errorf("JSON unmarshal failed for status check..ERROR: %v", err)
@@ -412,22 +375,17 @@ func (p *PccClient) GetAllCephFS(cephClusterId uint64) (cephFSList []*models.Cep
 
 func (p *PccClient) CreateCephFS(request CreateCephFSRequest) (id uint64, err error) {
 	var (
-		body              []byte
 		data              []byte
-		resp              HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/fs")
 	if data, err = json.Marshal(request); err != nil {
 		err = fmt.Errorf("Invalid struct for ceph fs creation..ERROR: %v", err)
 	}else {
-		if resp, body, err = p.pccGateway("POST", endpoint, data); err != nil {
-			err = fmt.Errorf("%v\n%v\n", string(body), err)
-		} else {
-			if resp.Status != 200 {
-				fmt.Printf("Ceph FS creation failed:\n%v\n", string(body))
-				err = fmt.Errorf("%v\n", string(body))
+		if _, _, err = p.pccGateway("POST", endpoint, data); err == nil {
+			if errSleep := p.Sleep(time.Second * 5); errSleep != nil {
+				err = errSleep
+				return
 			}
-			time.Sleep(time.Second * 5)
 			cephFS, errGet := p.GetCephFS(request.Name, request.CephClusterId)
 			if errGet == nil {
 				if cephFS != nil {
@@ -443,131 +401,42 @@ func (p *PccClient) CreateCephFS(request CreateCephFSRequest) (id uint64, err er
 
 func (p *PccClient) DeleteCephFS(id uint64) (err error) {
 	var (
-		body              []byte
 		data              []byte
-		resp              HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/storage/ceph/fs/%v", id)
-	if resp, body, err = p.pccGateway("DELETE", endpoint, data); err != nil {
-		return fmt.Errorf("%v\n%v\n", string(body), err)
-	}
-	if resp.Status != 200 {
-		return fmt.Errorf("%v\n", string(body))
+	if _, _, err = p.pccGateway("DELETE", endpoint, data); err != nil {
+		return err
 	}
 	return nil
 }
 
 func (config *CephConfiguration) VerifyCeph(startTime time.Time, action string, name string) (s Status, err error){
-	s = config.PccClient.Verify(startTime, config.getCephVerifier(action, name))
-
-	failed := !( strings.Contains(s.Msg, CEPH_INSTALLATION_SUCCESS_NOTIFICATION) ||
-		strings.Contains(s.Msg, CEPH_UNINSTALLATION_SUCCESS_NOTIFICATION) ||
-		strings.Contains(s.Msg, fmt.Sprintf(CEPH_POOL_CREATION_SUCCESS_NOTIFICATION, name, config.ClusterName)) ||
-		strings.Contains(s.Msg, fmt.Sprintf(CEPH_POOL_DELETION_SUCCESS_NOTIFICATION, name)) ||
-		strings.Contains(s.Msg, fmt.Sprintf(CEPH_FS_CREATION_SUCCESS_NOTIFICATION, name, config.ClusterName)) ||
-		strings.Contains(s.Msg, fmt.Sprintf(CEPH_FS_DELETION_SUCCESS_NOTIFICATION, name)) )
-	if s.IsError || failed {
+	v, err := config.PccClient.Catalog().Verifier(action, config.catalogVars(name))
+	if err != nil {
+		return
+	}
+	s = config.PccClient.Verify(startTime, v)
+	if s.IsError {
 		err = fmt.Errorf("%v", s.Msg)
 	}
 	return
 }
 
-type CephVerifier struct {
-	timeout time.Duration
-	events EventsToCheck
-	breakLoop chan bool
-}
-
-func (v *CephVerifier) GetTimeout() time.Duration {
-	return v.timeout
-}
-
-func (v *CephVerifier) GetEventsToCheck() EventsToCheck {
-	return v.events
-}
-
-func (v *CephVerifier) GetBreakLoopChan() chan bool {
-	return v.breakLoop
+// catalogVars fill the placeholders of the Ceph workflows of a catalog
+// for the pool or FS name.
+func (config *CephConfiguration) catalogVars(name string) map[string]string {
+	return map[string]string{
+		"name":    name,
+		"cluster": config.GetCephClusterName(),
+	}
 }
 
-func (config *CephConfiguration) getCephVerifier(action string, name string) (v *CephVerifier){
-	switch action {
-	case CEPH_CLUSTER_INSTALL_EVENT:
-		v = &CephVerifier{
-			timeout: CEPH_3_NODE_INSTALLATION_TIMEOUT,
-			events: EventsToCheck{
-				CEPH_INSTALLATION_SUCCESS_NOTIFICATION : true,
-				fmt.Sprintf(CEPH_INSTALLATION_FAILED_NOTIFICATION_1, config.GetCephClusterName()): true,
-				CEPH_INSTALLATION_FAILED_NOTIFICATION_2: true,
-				CEPH_INSTALLATION_FAILED_NOTIFICATION_3: true,
-				fmt.Sprintf(CEPH_INSTALLATION_FAILED_NOTIFICATION_4, config.GetCephClusterName()): true,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_1: false,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_2: false,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_3: false,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_4: false,
-				fmt.Sprintf(CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_5, config.GetCephClusterName()): false,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_6: false,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_7: false,
-				CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_8: false,
-			},
-			breakLoop: make(chan bool),
-		}
-	case CEPH_CLUSTER_UNINSTALL_EVENT:
-		v = &CephVerifier{
-			timeout: CEPH_3_NODE_UNINSTALLATION_TIMEOUT,
-			events: EventsToCheck{
-				CEPH_UNINSTALLATION_SUCCESS_NOTIFICATION: true,
-				fmt.Sprintf(CEPH_UNINSTALLATION_FAILED_NOTIFICATION_1, config.GetCephClusterName()): true,
-				fmt.Sprintf(CEPH_UNINSTALLATION_FAILED_NOTIFICATION_2, config.GetCephClusterName()): true,
-				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_1: false,
-				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_2: false,
-				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_3: false,
-				CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_4: false,
-			},
-			breakLoop: make(chan bool),
-		}
-	case CEPH_POOL_CREATE_EVENT:
-		v = &CephVerifier{
-			timeout: CEPH_POOL_CREATION_TIMEOUT,
-			events: EventsToCheck{
-				fmt.Sprintf(CEPH_POOL_CREATION_SUCCESS_NOTIFICATION, name, config.GetCephClusterName()): true,
-				fmt.Sprintf(CEPH_POOL_CREATION_FAILED_NOTIFICATION, name, config.GetCephClusterName()): true,
-				fmt.Sprintf(CEPH_POOL_CREATION_INTERMEDIATE_NOTIFICATION_1, name, config.GetCephClusterName()): false,
-			},
-			breakLoop: make(chan bool),
-		}
-	case CEPH_POOL_DELETE_EVENT:
-		v = &CephVerifier{
-			timeout: CEPH_POOL_DELETION_TIMEOUT,
-			events: EventsToCheck{
-				fmt.Sprintf(CEPH_POOL_DELETION_SUCCESS_NOTIFICATION, name): true,
-				fmt.Sprintf(CEPH_POOL_DELETION_FAILED_NOTIFICATION, name): true,
-				fmt.Sprintf(CEPH_POOL_DELETION_INTERMEDIATE_NOTIFICATION_1, name, config.GetCephClusterName()): false,
-			},
-			breakLoop: make(chan bool),
-		}
-	case CEPH_FS_CREATE_EVENT:
-		v = &CephVerifier{
-			timeout: CEPH_FS_CREATION_TIMEOUT,
-			events: EventsToCheck{
-				fmt.Sprintf(CEPH_FS_CREATION_SUCCESS_NOTIFICATION, name, config.GetCephClusterName()): true,
-				fmt.Sprintf(CEPH_FS_CREATION_FAILED_NOTIFICATION_1, name, config.GetCephClusterName()): true,
-				fmt.Sprintf(CEPH_FS_CREATION_FAILED_NOTIFICATION_2, name, config.GetCephClusterName()): true,
-				fmt.Sprintf(CEPH_FS_CREATION_INTERMEDIATE_NOTIFICATION_1, name, config.GetCephClusterName()): false,
-			},
-			breakLoop: make(chan bool),
-		}
-	case CEPH_FS_DELETE_EVENT:
-		v = &CephVerifier{
-			timeout: CEPH_FS_DELETION_TIMEOUT,
-			events: EventsToCheck{
-				fmt.Sprintf(CEPH_FS_DELETION_SUCCESS_NOTIFICATION, name): true,
-				fmt.Sprintf(CEPH_FS_DELETION_FAILED_NOTIFICATION_1, name): true,
-				fmt.Sprintf(CEPH_FS_DELETION_FAILED_NOTIFICATION_2, name): true,
-				fmt.Sprintf(CEPH_FS_DELETION_INTERMEDIATE_NOTIFICATION_1, name): false,
-			},
-			breakLoop: make(chan bool),
-		}
-	}
+// InstallSequence returns the milestones of the installation of the
+// cluster.
+func (config *CephConfiguration) InstallSequence() (s Sequence, err error) {
+	name := fmt.Sprintf("Ceph cluster %v install", config.GetCephClusterName())
+	s, err = config.PccClient.Catalog().Sequence(CEPH_CLUSTER_INSTALL_EVENT,
+		name, config.catalogVars(config.GetCephClusterName()))
+	s.ClusterId = config.GetCephClusterId()
 	return
-}
\ No newline at end of file
+}
@@ -0,0 +1,47 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import "time"
+
+// Clock tells the time to the waits of lib, so that tests can drive them
+// with a fake one instead of waiting for real.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the clock of the clients with no clock of their own.
+var SystemClock Clock = systemClock{}
+
+// WithClock returns a copy of p that waits on c.
+func (p *PccClient) WithClock(c Clock) *PccClient {
+	p2 := *p
+	p2.clock = c
+	return &p2
+}
+
+// Clock returns the clock p waits on.
+func (p *PccClient) Clock() Clock {
+	if p.clock != nil {
+		return p.clock
+	}
+	return SystemClock
+}
+
+// Since returns the time elapsed since t on the clock of p.
+func (p *PccClient) Since(t time.Time) time.Duration {
+	return p.Clock().Now().Sub(t)
+}
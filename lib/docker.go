@@ -2,24 +2,116 @@ package pcc
 
 import (
 	"bufio"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
-	"github.com/KyleBanks/dockerstats"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
+
+	"github.com/KyleBanks/dockerstats"
 )
 
+// DockerStatsConfig says where to write the stats, how often to sample
+// them and from which source, see NewDockerSource, and the bounds they
+// are checked against.  Goroutines maps the name of a Go container to
+// the URL of its goroutine profile, see countGoroutines.
 type DockerStatsConfig struct {
 	OutputFile string
+	CSVFile    string
+	JSONFile   string
 	Period     uint16
+	Source     string
+	Host       string
+	SSH        DockerSSHConfig
+	Budgets    []DockerBudget
+	Baseline   DockerBaselineConfig
+	Goroutines map[string]string
+}
+
+// DockerSample is the usage of a container at a time of a phase.  CPU is
+// in percent of one CPU, Memory and MemoryLimit in bytes.  FDs, the
+// files open by the main process, is only counted by Sample, and 0 when
+// the source can't.  So are Goroutines, for the containers of
+// DockerStatsConfig.Goroutines.
+type DockerSample struct {
+	Time          time.Time `json:"time"`
+	Phase         string    `json:"phase"`
+	Container     string    `json:"container"`
+	CPU           float64   `json:"cpu"`
+	Memory        uint64    `json:"memory"`
+	MemoryLimit   uint64    `json:"memoryLimit"`
+	MemoryPercent float64   `json:"memoryPercent"`
+	NetIO         string    `json:"netIO"`
+	BlockIO       string    `json:"blockIO"`
+	PIDs          int       `json:"pids"`
+	FDs           int       `json:"fds,omitempty"`
+	Goroutines    int       `json:"goroutines,omitempty"`
+}
+
+// ContainerSummary sums up the samples of a container in a phase.
+type ContainerSummary struct {
+	Container string
+	Samples   int
+	CPUMin    float64
+	CPUAvg    float64
+	CPUMax    float64
+	MemoryMin uint64
+	MemoryAvg uint64
+	MemoryMax uint64
+}
+
+// PhaseSummary sums up the samples of a phase, by container.
+type PhaseSummary struct {
+	Phase      string
+	Start      time.Time
+	End        time.Time
+	Containers []ContainerSummary
+}
+
+func (s *PhaseSummary) Elapsed() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type containerTotals struct {
+	ContainerSummary
+	cpuSum    float64
+	memorySum uint64
 }
 
+type phaseTotals struct {
+	phase      string
+	start      time.Time
+	end        time.Time
+	containers map[string]*containerTotals
+}
+
+var csvHeader = []string{"time", "phase", "container", "cpu", "memory",
+	"memoryLimit", "memoryPercent", "netIO", "blockIO", "pids", "fds",
+	"goroutines"}
+
 type DockerStats struct {
+	config   DockerStatsConfig
 	fileName string
 	phase    string
 	start    *time.Time
 	timer    *time.Timer
 	file     *os.File
 	writer   *bufio.Writer
+	csvFile  *os.File
+	csv      *csv.Writer
+	jsonFile *os.File
+	json     *bufio.Writer
+	source   DockerSource
+
+	mu      sync.Mutex
+	phases  []*phaseTotals
+	metrics *Metrics
 }
 
 // Init
@@ -27,63 +119,369 @@ func InitDockerStats(config DockerStatsConfig) *DockerStats {
 	if config.OutputFile == "" {
 		config.OutputFile = "container-stats.txt"
 	}
+	if config.CSVFile == "" {
+		config.CSVFile = "container-stats.csv"
+	}
+	if config.JSONFile == "" {
+		config.JSONFile = "container-stats.jsonl"
+	}
 
 	if config.Period <= 0 {
 		config.Period = 30
 	}
 
 	var err error
-	dockerStats := DockerStats{fileName: config.OutputFile}
-	if dockerStats.file, err = os.Create(dockerStats.fileName); err == nil {
-		dockerStats.writer = bufio.NewWriter(dockerStats.file)
-		collect := func() {
-
-			t := time.Now().Format(time.RFC3339)
-			if stats, err := dockerstats.Current(); err == nil {
-				for _, s := range stats {
-					container := s.Container
-					memory := s.Memory
-					cpu := s.CPU
-					io := s.IO
-					pids := s.PIDs
-					dockerStats.writer.WriteString(fmt.Sprintf("\n%s CONTAINER=%s: CPU=%v; MEMORY=%s; IO=%s; PIDS=%d", t, container, cpu, memory.String(), io.String(), pids))
-					dockerStats.writer.Flush()
-				}
-			} else {
-				fmt.Println("error collecting docker stats", err)
-			}
-
-			dockerStats.writer.Flush()
-			dockerStats.timer.Reset(time.Second * time.Duration(config.Period)) // Write every 45s
-		}
-		dockerStats.timer = time.AfterFunc(time.Second*time.Duration(config.Period), collect) // start collect
-	} else {
+	dockerStats := DockerStats{
+		config:   config,
+		fileName: config.OutputFile,
+	}
+	if dockerStats.source, err = NewDockerSource(config); err != nil {
+		panic(err)
+	}
+	if dockerStats.file, err = os.Create(dockerStats.fileName); err != nil {
 		panic(err)
 	}
+	if dockerStats.csvFile, err = os.Create(config.CSVFile); err != nil {
+		panic(err)
+	}
+	if dockerStats.jsonFile, err = os.Create(config.JSONFile); err != nil {
+		panic(err)
+	}
+	dockerStats.writer = bufio.NewWriter(dockerStats.file)
+	dockerStats.csv = csv.NewWriter(dockerStats.csvFile)
+	dockerStats.csv.Write(csvHeader)
+	dockerStats.json = bufio.NewWriter(dockerStats.jsonFile)
+	collect := func() {
+		dockerStats.collect()
+		dockerStats.timer.Reset(time.Second * time.Duration(config.Period)) // Write every 45s
+	}
+	dockerStats.timer = time.AfterFunc(time.Second*time.Duration(config.Period), collect) // start collect
 
 	return &dockerStats
 }
 
+// collect samples the containers, writing a record per container.
+func (ds *DockerStats) collect() {
+	if _, err := ds.sample(false); err != nil {
+		DefaultLogger.Log(LOG_ERROR, "error collecting docker stats",
+			Fields{"error": err})
+	}
+}
+
+// Sample samples the containers now, as they are every period, and
+// returns the samples, with the files open by each when the source can
+// count them, and the goroutines of the Go containers configured.
+func (ds *DockerStats) Sample() ([]DockerSample, error) {
+	return ds.sample(true)
+}
+
+func (ds *DockerStats) sample(withFDs bool) (samples []DockerSample,
+	err error) {
+
+	var (
+		stats      []dockerstats.Stats
+		fds        map[string]int
+		goroutines map[string]int
+	)
+
+	if samples, stats, err = ds.read(); err != nil {
+		return
+	}
+	if fs, ok := ds.source.(DockerFDSource); ok && withFDs {
+		if fds, err = fs.FDs(); err != nil {
+			return
+		}
+	}
+	if withFDs && len(ds.config.Goroutines) > 0 {
+		if goroutines, err = countGoroutines(
+			ds.config.Goroutines); err != nil {
+			return
+		}
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	now := time.Now()
+	t := now.Format(time.RFC3339)
+	for i, s := range stats {
+		container := s.Container
+		memory := s.Memory
+		cpu := s.CPU
+		io := s.IO
+		pids := s.PIDs
+		ds.writer.WriteString(fmt.Sprintf("\n%s CONTAINER=%s: CPU=%v; MEMORY=%s; IO=%s; PIDS=%d", t, container, cpu, memory.String(), io.String(), pids))
+
+		sample := &samples[i]
+		sample.Time = now
+		sample.Phase = ds.phase
+		sample.FDs = fds[container]
+		sample.Goroutines = goroutines[container]
+		ds.record(sample)
+	}
+	ds.writer.Flush()
+	ds.csv.Flush()
+	ds.json.Flush()
+	return
+}
+
+// read reads the containers from the source, as figures and as docker
+// stats prints them.  The figures are only parsed from the text for the
+// sources that have nothing else.
+func (ds *DockerStats) read() (samples []DockerSample,
+	stats []dockerstats.Stats, err error) {
+
+	if src, ok := ds.source.(DockerSampleSource); ok {
+		if samples, err = src.Samples(); err != nil {
+			return
+		}
+		for i := range samples {
+			stats = append(stats, samples[i].stats())
+		}
+		return
+	}
+	if stats, err = ds.source.Stats(); err != nil {
+		return
+	}
+	for _, s := range stats {
+		samples = append(samples, sampleOf(s))
+	}
+	return
+}
+
+// sampleOf parses the figures of s.
+func sampleOf(s dockerstats.Stats) (sample DockerSample) {
+	sample = DockerSample{
+		Container: s.Container,
+		NetIO:     s.IO.Network,
+		BlockIO:   s.IO.Block,
+		PIDs:      s.PIDs,
+	}
+	sample.CPU, _ = parsePercent(s.CPU)
+	sample.MemoryPercent, _ = parsePercent(s.Memory.Percent)
+	// "used / limit"
+	usage := strings.SplitN(s.Memory.Raw, "/", 2)
+	sample.Memory, _ = parseSize(usage[0])
+	if len(usage) > 1 {
+		sample.MemoryLimit, _ = parseSize(usage[1])
+	}
+	return
+}
+
+// stats formats s as docker stats prints it.
+func (s *DockerSample) stats() (st dockerstats.Stats) {
+	st.Container = s.Container
+	st.CPU = fmt.Sprintf("%.2f%%", s.CPU)
+	st.Memory.Raw = fmt.Sprintf("%v / %v", formatSize(s.Memory),
+		formatSize(s.MemoryLimit))
+	st.Memory.Percent = fmt.Sprintf("%.2f%%", s.MemoryPercent)
+	st.IO.Network = s.NetIO
+	st.IO.Block = s.BlockIO
+	st.PIDs = s.PIDs
+	return
+}
+
+func (ds *DockerStats) record(s *DockerSample) {
+	ds.csv.Write([]string{
+		s.Time.Format(time.RFC3339),
+		s.Phase,
+		s.Container,
+		strconv.FormatFloat(s.CPU, 'f', 2, 64),
+		strconv.FormatUint(s.Memory, 10),
+		strconv.FormatUint(s.MemoryLimit, 10),
+		strconv.FormatFloat(s.MemoryPercent, 'f', 2, 64),
+		s.NetIO,
+		s.BlockIO,
+		strconv.Itoa(s.PIDs),
+		strconv.Itoa(s.FDs),
+		strconv.Itoa(s.Goroutines),
+	})
+	if b, err := json.Marshal(s); err == nil {
+		ds.json.Write(b)
+		ds.json.WriteString("\n")
+	}
+	ds.metrics.observeDockerSample(s)
+
+	if len(ds.phases) == 0 {
+		ds.phases = append(ds.phases, &phaseTotals{
+			start:      s.Time,
+			containers: make(map[string]*containerTotals),
+		})
+	}
+	p := ds.phases[len(ds.phases)-1]
+	c := p.containers[s.Container]
+	if c == nil {
+		c = &containerTotals{ContainerSummary: ContainerSummary{
+			Container: s.Container,
+			CPUMin:    s.CPU,
+			MemoryMin: s.Memory,
+		}}
+		p.containers[s.Container] = c
+	}
+	c.Samples++
+	c.cpuSum += s.CPU
+	c.memorySum += s.Memory
+	if s.CPU < c.CPUMin {
+		c.CPUMin = s.CPU
+	}
+	if s.CPU > c.CPUMax {
+		c.CPUMax = s.CPU
+	}
+	if s.Memory < c.MemoryMin {
+		c.MemoryMin = s.Memory
+	}
+	if s.Memory > c.MemoryMax {
+		c.MemoryMax = s.Memory
+	}
+}
+
 // Switch test phase
 func (ds *DockerStats) ChangePhase(name string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	end := time.Now()
 	if ds.phase != "" {
 		start := ds.start
-		end := time.Now()
 		if ds.start != nil {
 			_, _ = ds.writer.WriteString(fmt.Sprintf("\nEND %s; STARTTIME=%s; ENDTIME=%s; ELAPSEDTIME=%s", ds.phase, (*start).Format(time.RFC3339), end.Format(time.RFC3339), end.Sub(*start).String()))
 		}
-		ds.start = &end
-		_, _ = ds.writer.WriteString(fmt.Sprintf("\n\nSTART %s; STARTTIME=%s", name, ds.start.Format(time.RFC3339)))
+		_, _ = ds.writer.WriteString(fmt.Sprintf("\n\nSTART %s; STARTTIME=%s", name, end.Format(time.RFC3339)))
 		ds.writer.Flush()
 	}
+	ds.start = &end
+	if n := len(ds.phases); n > 0 {
+		ds.phases[n-1].end = end
+	}
+	ds.phases = append(ds.phases, &phaseTotals{
+		phase:      name,
+		start:      end,
+		containers: make(map[string]*containerTotals),
+	})
 
 	ds.phase = name
 	ds.timer.Reset(time.Second * time.Duration(1))
 }
 
+// ExportMetrics sets the gauges of each container in m at every sample.
+func (ds *DockerStats) ExportMetrics(m *Metrics) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.metrics = m
+}
+
+// Summary sums up the samples of each phase so far, in the order the
+// phases ran.
+func (ds *DockerStats) Summary() (summary []PhaseSummary) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range ds.phases {
+		s := PhaseSummary{Phase: p.phase, Start: p.start, End: p.end}
+		if s.End.IsZero() {
+			s.End = now
+		}
+		for _, c := range p.containers {
+			cs := c.ContainerSummary
+			cs.CPUAvg = c.cpuSum / float64(c.Samples)
+			cs.MemoryAvg = c.memorySum / uint64(c.Samples)
+			s.Containers = append(s.Containers, cs)
+		}
+		sort.Slice(s.Containers, func(i, j int) bool {
+			return s.Containers[i].Container <
+				s.Containers[j].Container
+		})
+		summary = append(summary, s)
+	}
+	return
+}
+
+// WriteDockerSummary writes summary as a table, a row per phase and
+// container.
+func WriteDockerSummary(w io.Writer, summary []PhaseSummary) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tELAPSED\tCONTAINER\tSAMPLES\t"+
+		"CPU MIN\tCPU AVG\tCPU MAX\tMEM MIN\tMEM AVG\tMEM MAX")
+	for _, s := range summary {
+		elapsed := s.Elapsed().Round(time.Second)
+		if len(s.Containers) == 0 {
+			fmt.Fprintf(tw, "%v\t%v\t-\t0\t\t\t\t\t\t\n", s.Phase,
+				elapsed)
+		}
+		for _, c := range s.Containers {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%.2f%%\t%.2f%%\t%.2f%%"+
+				"\t%v\t%v\t%v\n", s.Phase, elapsed, c.Container,
+				c.Samples, c.CPUMin, c.CPUAvg, c.CPUMax,
+				formatSize(c.MemoryMin), formatSize(c.MemoryAvg),
+				formatSize(c.MemoryMax))
+		}
+	}
+	tw.Flush()
+}
+
 // Stop
 func (ds *DockerStats) Stop() {
+	ds.timer.Stop()
+	ds.mu.Lock()
+	if n := len(ds.phases); n > 0 && ds.phases[n-1].end.IsZero() {
+		ds.phases[n-1].end = time.Now()
+	}
+	ds.mu.Unlock()
+	summary := ds.Summary()
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
 	defer ds.file.Close()
+	defer ds.csvFile.Close()
+	defer ds.jsonFile.Close()
+	ds.writer.WriteString("\n\nSUMMARY\n")
+	WriteDockerSummary(ds.writer, summary)
 	ds.writer.Flush()
-	ds.timer.Stop()
+	ds.csv.Flush()
+	ds.json.Flush()
+}
+
+// parsePercent parses docker's "12.34%".
+func parsePercent(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s),
+		"%"), 64)
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseSize parses docker's sizes, e.g. "1.5MiB" or "10kB", in bytes.
+func parseSize(s string) (size uint64, err error) {
+	s = strings.TrimSpace(s)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			var f float64
+
+			f, err = strconv.ParseFloat(strings.TrimSpace(
+				strings.TrimSuffix(s, u.suffix)), 64)
+			size = uint64(f * u.bytes)
+			return
+		}
+	}
+	err = fmt.Errorf("bad size %q", s)
+	return
+}
+
+func formatSize(size uint64) string {
+	const unit = 1 << 10
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	f, exp := float64(size)/unit, 0
+	for f >= unit && exp < 3 {
+		f /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", f, "KMGT"[exp])
 }
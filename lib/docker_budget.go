@@ -0,0 +1,245 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+const DEFAULT_BASELINE_TOLERANCE = 20
+
+// DockerBudget bounds the usage of Container during Phase, any
+// container or phase when empty.  CPU is in percent of one CPU, memory a
+// size as docker shows it, e.g. "2GiB"; a zero or empty bound is not
+// checked.  Exceeding it fails the suite with Fail, else only warns.
+type DockerBudget struct {
+	Phase        string
+	Container    string
+	MaxCPU       uint16
+	MaxCPUAvg    uint16
+	MaxMemory    string
+	MaxMemoryAvg string
+	Fail         bool
+}
+
+// DockerBaselineConfig compares the usage of a run with that of a
+// previous one saved in File: the average CPU or peak memory of a
+// container in a phase more than Tolerance percent (20 by default) above
+// the baseline is a regression, failing the suite with Fail.  Save is
+// where to save the usage of this run, to serve as a baseline.
+type DockerBaselineConfig struct {
+	File      string
+	Save      string
+	Tolerance uint16
+	Fail      bool
+}
+
+// DockerViolation is a bound exceeded by a container during a phase.
+type DockerViolation struct {
+	Phase     string
+	Container string
+	What      string
+	Value     string
+	Limit     string
+	Fail      bool
+}
+
+func (v DockerViolation) String() string {
+	severity := "WARNING"
+	if v.Fail {
+		severity = "FAILURE"
+	}
+	return fmt.Sprintf("%v: %v %v during %v: %v, over %v", severity,
+		v.Container, v.What, v.Phase, v.Value, v.Limit)
+}
+
+// DockerViolations are the bounds exceeded during a run.
+type DockerViolations []DockerViolation
+
+// Failed tells whether one of v fails the suite.
+func (v DockerViolations) Failed() bool {
+	for _, violation := range v {
+		if violation.Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Check checks the usage of each phase so far against the budgets and
+// the baseline of ds.
+func (ds *DockerStats) Check() (violations DockerViolations, err error) {
+	summary := ds.Summary()
+	for _, b := range ds.config.Budgets {
+		var v []DockerViolation
+
+		if v, err = b.check(summary); err != nil {
+			return
+		}
+		violations = append(violations, v...)
+	}
+	if ds.config.Baseline.File != "" {
+		var baseline []PhaseSummary
+
+		if baseline, err = LoadDockerSummary(
+			ds.config.Baseline.File); err != nil {
+			return
+		}
+		violations = append(violations,
+			ds.config.Baseline.compare(summary, baseline)...)
+	}
+	return
+}
+
+// CheckAndSaveBaseline checks the usage of the run, then saves it as a
+// baseline, so a run saving to the baseline it is compared with is
+// compared with the previous run, not with itself.
+func (ds *DockerStats) CheckAndSaveBaseline() (violations DockerViolations,
+	err error) {
+
+	if violations, err = ds.Check(); err != nil {
+		err = fmt.Errorf("check: %v", err)
+		return
+	}
+	if err = ds.SaveBaseline(); err != nil {
+		err = fmt.Errorf("save baseline: %v", err)
+	}
+	return
+}
+
+// SaveBaseline saves the usage of the run where Baseline.Save says, if
+// anywhere.
+func (ds *DockerStats) SaveBaseline() error {
+	if ds.config.Baseline.Save == "" {
+		return nil
+	}
+	return SaveDockerSummary(ds.config.Baseline.Save, ds.Summary())
+}
+
+func (b *DockerBudget) check(summary []PhaseSummary) (
+	violations []DockerViolation, err error) {
+
+	var maxMemory, maxMemoryAvg uint64
+
+	if b.MaxMemory != "" {
+		if maxMemory, err = parseSize(b.MaxMemory); err != nil {
+			return
+		}
+	}
+	if b.MaxMemoryAvg != "" {
+		if maxMemoryAvg, err = parseSize(b.MaxMemoryAvg); err != nil {
+			return
+		}
+	}
+	for _, s := range summary {
+		if b.Phase != "" && b.Phase != s.Phase {
+			continue
+		}
+		for _, c := range s.Containers {
+			if b.Container != "" && b.Container != c.Container {
+				continue
+			}
+			violation := func(what, value, limit string) {
+				violations = append(violations, DockerViolation{
+					Phase:     s.Phase,
+					Container: c.Container,
+					What:      what,
+					Value:     value,
+					Limit:     limit,
+					Fail:      b.Fail,
+				})
+			}
+			if b.MaxCPU != 0 && c.CPUMax > float64(b.MaxCPU) {
+				violation("CPU", fmt.Sprintf("%.2f%%", c.CPUMax),
+					fmt.Sprintf("%v%%", b.MaxCPU))
+			}
+			if b.MaxCPUAvg != 0 && c.CPUAvg > float64(b.MaxCPUAvg) {
+				violation("CPU average",
+					fmt.Sprintf("%.2f%%", c.CPUAvg),
+					fmt.Sprintf("%v%%", b.MaxCPUAvg))
+			}
+			if maxMemory != 0 && c.MemoryMax > maxMemory {
+				violation("memory", formatSize(c.MemoryMax),
+					b.MaxMemory)
+			}
+			if maxMemoryAvg != 0 && c.MemoryAvg > maxMemoryAvg {
+				violation("memory average",
+					formatSize(c.MemoryAvg), b.MaxMemoryAvg)
+			}
+		}
+	}
+	return
+}
+
+func (config *DockerBaselineConfig) compare(summary []PhaseSummary,
+	baseline []PhaseSummary) (violations []DockerViolation) {
+
+	tolerance := config.Tolerance
+	if tolerance == 0 {
+		tolerance = DEFAULT_BASELINE_TOLERANCE
+	}
+	factor := 1 + float64(tolerance)/100
+
+	base := make(map[string]ContainerSummary)
+	for _, s := range baseline {
+		for _, c := range s.Containers {
+			base[s.Phase+"/"+c.Container] = c
+		}
+	}
+	for _, s := range summary {
+		for _, c := range s.Containers {
+			b, found := base[s.Phase+"/"+c.Container]
+			if !found {
+				continue
+			}
+			violation := func(what, value, limit string) {
+				violations = append(violations, DockerViolation{
+					Phase:     s.Phase,
+					Container: c.Container,
+					What:      what,
+					Value:     value,
+					Limit:     limit,
+					Fail:      config.Fail,
+				})
+			}
+			if c.CPUAvg > b.CPUAvg*factor {
+				violation("CPU average",
+					fmt.Sprintf("%.2f%%", c.CPUAvg),
+					fmt.Sprintf("baseline %.2f%% +%v%%",
+						b.CPUAvg, tolerance))
+			}
+			if float64(c.MemoryMax) > float64(b.MemoryMax)*factor {
+				violation("memory", formatSize(c.MemoryMax),
+					fmt.Sprintf("baseline %v +%v%%",
+						formatSize(b.MemoryMax), tolerance))
+			}
+		}
+	}
+	return
+}
+
+// SaveDockerSummary saves summary as JSON in file, e.g. as the baseline
+// of the next runs.
+func SaveDockerSummary(file string, summary []PhaseSummary) error {
+	b, err := json.MarshalIndent(summary, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
+
+// LoadDockerSummary loads a summary saved by SaveDockerSummary.
+func LoadDockerSummary(file string) (summary []PhaseSummary, err error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &summary); err != nil {
+		err = fmt.Errorf("%v: %w", file, err)
+	}
+	return
+}
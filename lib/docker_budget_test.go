@@ -0,0 +1,198 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// usage is a summary of two phases of the pccserver and redis containers.
+var usage = []PhaseSummary{
+	{Phase: "installMAAS", Containers: []ContainerSummary{
+		{Container: "pccserver", Samples: 10, CPUAvg: 120, CPUMax: 180,
+			MemoryAvg: 1 << 30, MemoryMax: 1536 << 20},
+		{Container: "redis", Samples: 10, CPUAvg: 2, CPUMax: 5,
+			MemoryAvg: 10 << 20, MemoryMax: 12 << 20},
+	}},
+	{Phase: "addNodes", Containers: []ContainerSummary{
+		{Container: "pccserver", Samples: 4, CPUAvg: 40, CPUMax: 60,
+			MemoryAvg: 900 << 20, MemoryMax: 1 << 30},
+	}},
+}
+
+func violated(violations []DockerViolation) (what []string) {
+	for _, v := range violations {
+		what = append(what, v.Phase+" "+v.Container+" "+v.What)
+	}
+	return
+}
+
+func TestDockerBudget(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		budget DockerBudget
+		want   []string
+	}{
+		{"within", DockerBudget{MaxCPU: 200, MaxMemory: "2GiB"}, nil},
+		{"cpu peak", DockerBudget{MaxCPU: 150},
+			[]string{"installMAAS pccserver CPU"}},
+		{"cpu average of a phase", DockerBudget{Phase: "addNodes",
+			MaxCPUAvg: 30}, []string{"addNodes pccserver CPU average"}},
+		{"memory of a container", DockerBudget{Container: "redis",
+			MaxMemory: "10MiB", MaxMemoryAvg: "10MiB"},
+			[]string{"installMAAS redis memory"}},
+		{"memory average", DockerBudget{MaxMemoryAvg: "950MiB"},
+			[]string{"installMAAS pccserver memory average"}},
+		{"unknown phase", DockerBudget{Phase: "deploy", MaxCPU: 1}, nil},
+	} {
+		violations, err := tc.budget.check(usage)
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		if got := violated(violations); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: violations %q, want %q", tc.name, got,
+				tc.want)
+		}
+		for _, v := range violations {
+			if v.Fail != tc.budget.Fail {
+				t.Errorf("%v: Fail %v", tc.name, v.Fail)
+			}
+		}
+	}
+
+	bad := DockerBudget{MaxMemory: "lots"}
+	if _, err := bad.check(usage); err == nil {
+		t.Error("no error for a bad size")
+	}
+}
+
+func TestDockerBaseline(t *testing.T) {
+	// pccserver now uses 25% more than in the baseline, redis about 10%
+	baseline := []PhaseSummary{
+		{Phase: "installMAAS", Containers: []ContainerSummary{
+			{Container: "pccserver", CPUAvg: 96,
+				MemoryMax: 1536 << 20 * 4 / 5},
+			{Container: "redis", CPUAvg: 1.8, MemoryMax: 21 << 19},
+		}},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		config DockerBaselineConfig
+		want   []string
+	}{
+		{"default tolerance", DockerBaselineConfig{}, []string{
+			"installMAAS pccserver CPU average",
+			"installMAAS pccserver memory",
+		}},
+		{"tolerance", DockerBaselineConfig{Tolerance: 30}, nil},
+		{"tight tolerance", DockerBaselineConfig{Tolerance: 10,
+			Fail: true}, []string{
+			"installMAAS pccserver CPU average",
+			"installMAAS pccserver memory",
+			"installMAAS redis CPU average",
+			"installMAAS redis memory",
+		}},
+	} {
+		violations := tc.config.compare(usage, baseline)
+		if got := violated(violations); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: regressions %q, want %q", tc.name, got,
+				tc.want)
+		}
+		for _, v := range violations {
+			if v.Fail != tc.config.Fail {
+				t.Errorf("%v: Fail %v", tc.name, v.Fail)
+			}
+		}
+	}
+}
+
+func TestDockerBaselineFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "baseline.json")
+
+	if err = SaveDockerSummary(file, usage); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := LoadDockerSummary(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(baseline, usage) {
+		t.Errorf("loaded %+v, saved %+v", baseline, usage)
+	}
+	// a run compared with itself doesn't regress
+	config := DockerBaselineConfig{Tolerance: 1}
+	if v := config.compare(usage, baseline); len(v) != 0 {
+		t.Errorf("regressions against itself: %v", v)
+	}
+}
+
+func TestDockerBaselineSameFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "baseline.json")
+
+	// the previous run, with pccserver at 80% of the usage of this one
+	previous := []PhaseSummary{
+		{Phase: "installMAAS", Containers: []ContainerSummary{
+			{Container: "pccserver", CPUAvg: 96,
+				MemoryMax: 1536 << 20 * 4 / 5},
+		}},
+	}
+	if err = SaveDockerSummary(file, previous); err != nil {
+		t.Fatal(err)
+	}
+	ds := &DockerStats{config: DockerStatsConfig{
+		Baseline: DockerBaselineConfig{File: file, Save: file},
+	}}
+	ds.phases = []*phaseTotals{{
+		phase: "installMAAS",
+		containers: map[string]*containerTotals{
+			"pccserver": {
+				ContainerSummary: ContainerSummary{
+					Container: "pccserver",
+					Samples:   1,
+					CPUMax:    120,
+					MemoryMax: 1536 << 20,
+				},
+				cpuSum:    120,
+				memorySum: 1536 << 20,
+			},
+		},
+	}}
+
+	violations, err := ds.CheckAndSaveBaseline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"installMAAS pccserver CPU average",
+		"installMAAS pccserver memory",
+	}
+	if got := violated(violations); !reflect.DeepEqual(got, want) {
+		t.Errorf("regressions %q, want %q", got, want)
+	}
+	saved, err := LoadDockerSummary(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || len(saved[0].Containers) != 1 ||
+		saved[0].Containers[0].CPUAvg != 120 {
+		t.Errorf("saved %+v, want the usage of the run", saved)
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/KyleBanks/dockerstats"
+)
+
+const (
+	DOCKER_SOURCE_LOCAL = "local"
+	DOCKER_SOURCE_API   = "api"
+	DOCKER_SOURCE_SSH   = "ssh"
+
+	DOCKER_API_PORT = 2375
+)
+
+// DockerSSHConfig tells how to reach the PCC host over ssh to run
+// docker stats there.
+type DockerSSHConfig struct {
+	Host    string
+	User    string
+	Port    uint16
+	KeyFile string
+}
+
+// DockerSource samples the usage of the containers of a Docker host.
+type DockerSource interface {
+	Stats() ([]dockerstats.Stats, error)
+}
+
+// DockerFDSource is a DockerSource that also counts the files open by
+// the main process of each container, by name.
+type DockerFDSource interface {
+	DockerSource
+	FDs() (map[string]int, error)
+}
+
+// DockerSampleSource is a DockerSource that reads the figures of each
+// container as numbers, e.g. the bytes of memory, rather than as docker
+// stats prints them.  Time, Phase and FDs are left unset.
+type DockerSampleSource interface {
+	DockerSource
+	Samples() ([]DockerSample, error)
+}
+
+// countFDs prints the name of each container and how many files its main
+// process has open.
+const countFDs = `docker ps -q | while read c; do ` +
+	`set -- $(docker inspect -f '{{.Name}} {{.State.Pid}}' $c); ` +
+	`echo $1 $(ls /proc/$2/fd 2>/dev/null | wc -l); done`
+
+// parseFDs parses the output of countFDs.
+func parseFDs(out []byte) (fds map[string]int) {
+	fds = make(map[string]int)
+	for _, line := range strings.Split(string(out), "\n") {
+		var (
+			name string
+			n    int
+		)
+
+		if _, err := fmt.Sscan(line, &name, &n); err == nil {
+			fds[strings.TrimPrefix(name, "/")] = n
+		}
+	}
+	return
+}
+
+// countGoroutines reads the goroutine profile of each container of urls,
+// by name, as net/http/pprof serves it, e.g.
+// http://pcc:6060/debug/pprof/goroutine?debug=1, and returns how many
+// goroutines each runs.
+func countGoroutines(urls map[string]string) (goroutines map[string]int,
+	err error) {
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	goroutines = make(map[string]int)
+	for container, u := range urls {
+		var n int
+
+		if n, err = readGoroutines(client, u); err != nil {
+			err = fmt.Errorf("goroutines of %v: %v", container, err)
+			return
+		}
+		goroutines[container] = n
+	}
+	return
+}
+
+// readGoroutines reads the count of the goroutine profile at u.
+func readGoroutines(client *http.Client, u string) (n int, err error) {
+	r, err := client.Get(u)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%v: %v", u, r.Status)
+		return
+	}
+	line, err := bufio.NewReader(r.Body).ReadString('\n')
+	if err != nil {
+		err = fmt.Errorf("%v: %v", u, err)
+		return
+	}
+	if _, err = fmt.Sscanf(line, "goroutine profile: total %d",
+		&n); err != nil {
+		err = fmt.Errorf("%v: not a goroutine profile: %q", u,
+			strings.TrimSpace(line))
+	}
+	return
+}
+
+type localDockerSource struct{}
+
+// Stats runs docker stats on the machine of the test.
+func (localDockerSource) Stats() ([]dockerstats.Stats, error) {
+	return dockerstats.Current()
+}
+
+func (localDockerSource) FDs() (fds map[string]int, err error) {
+	out, err := exec.Command("sh", "-c", countFDs).Output()
+	if err == nil {
+		fds = parseFDs(out)
+	}
+	return
+}
+
+// NewDockerSource returns the source config says: the local docker by
+// default, the Docker Engine API at Host (tcp://host:port or
+// unix:///path, e.g. forwarded from the PCC host), or docker stats run
+// over ssh on the PCC host.
+func NewDockerSource(config DockerStatsConfig) (s DockerSource, err error) {
+	switch config.Source {
+	case "", DOCKER_SOURCE_LOCAL:
+		s = localDockerSource{}
+	case DOCKER_SOURCE_API:
+		s, err = NewDockerAPISource(config.Host)
+	case DOCKER_SOURCE_SSH:
+		if config.SSH.Host == "" {
+			err = fmt.Errorf("docker stats over ssh: no host")
+			return
+		}
+		s = &sshDockerSource{config: config.SSH}
+	default:
+		err = fmt.Errorf("unknown docker stats source %q", config.Source)
+	}
+	return
+}
+
+// DockerAPISource reads the stats of the containers from the Docker
+// Engine API.
+type DockerAPISource struct {
+	base   string
+	client *http.Client
+}
+
+// NewDockerAPISource returns the source of the Engine API at host,
+// tcp://host:port or unix:///path.
+func NewDockerAPISource(host string) (s *DockerAPISource, err error) {
+	var u *url.URL
+
+	if u, err = url.Parse(host); err != nil {
+		return
+	}
+	s = &DockerAPISource{client: &http.Client{Timeout: 30 * time.Second}}
+	switch u.Scheme {
+	case "tcp", "http":
+		s.base = "http://" + u.Host
+	case "unix":
+		path := u.Path
+		s.base = "http://docker"
+		s.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (
+				net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+	default:
+		err = fmt.Errorf("bad docker host %q", host)
+		s = nil
+	}
+	return
+}
+
+type dockerContainer struct {
+	Id    string
+	Names []string
+}
+
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+type dockerContainerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IoServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+	PidsStats struct {
+		Current int `json:"current"`
+	} `json:"pids_stats"`
+}
+
+func (s *DockerAPISource) get(path string, v interface{}) (err error) {
+	r, err := s.client.Get(s.base + path)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if r.StatusCode != http.StatusOK {
+		err = fmt.Errorf("docker %v: %v %s", path, r.Status,
+			bytes.TrimSpace(body))
+		return
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Stats reads the stats of each running container, as docker stats
+// shows them.
+func (s *DockerAPISource) Stats() (stats []dockerstats.Stats, err error) {
+	samples, err := s.Samples()
+	for i := range samples {
+		stats = append(stats, samples[i].stats())
+	}
+	return
+}
+
+// Samples reads the figures of each running container.
+func (s *DockerAPISource) Samples() (samples []DockerSample, err error) {
+	var containers []dockerContainer
+
+	if err = s.get("/containers/json", &containers); err != nil {
+		return
+	}
+	for _, c := range containers {
+		var cs dockerContainerStats
+
+		if err = s.get("/containers/"+c.Id+"/stats?stream=false",
+			&cs); err != nil {
+			return
+		}
+		name := c.Id
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		samples = append(samples, cs.toSample(name))
+	}
+	return
+}
+
+// toSample computes the figures of docker stats from the raw ones.
+func (cs *dockerContainerStats) toSample(name string) (s DockerSample) {
+	var cpu, memPercent float64
+
+	cpus := float64(cs.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(cs.CPUStats.CPUUsage.PercpuUsage))
+	}
+	cpuDelta := float64(cs.CPUStats.CPUUsage.TotalUsage) -
+		float64(cs.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(cs.CPUStats.SystemUsage) -
+		float64(cs.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		cpu = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// as docker stats, without the page cache
+	memory := cs.MemoryStats.Usage
+	cache := cs.MemoryStats.Stats["total_inactive_file"]
+	if cache == 0 {
+		cache = cs.MemoryStats.Stats["inactive_file"]
+	}
+	if cache < memory {
+		memory -= cache
+	}
+	if cs.MemoryStats.Limit != 0 {
+		memPercent = float64(memory) / float64(cs.MemoryStats.Limit) *
+			100
+	}
+
+	var rx, tx, read, write uint64
+	for _, n := range cs.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	for _, b := range cs.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(b.Op) {
+		case "read":
+			read += b.Value
+		case "write":
+			write += b.Value
+		}
+	}
+
+	s.Container = name
+	s.CPU = cpu
+	s.Memory = memory
+	s.MemoryLimit = cs.MemoryStats.Limit
+	s.MemoryPercent = memPercent
+	s.NetIO = fmt.Sprintf("%v / %v", formatSize(rx), formatSize(tx))
+	s.BlockIO = fmt.Sprintf("%v / %v", formatSize(read),
+		formatSize(write))
+	s.PIDs = cs.PidsStats.Current
+	return
+}
+
+// sshDockerSource runs docker stats on the PCC host over ssh.
+type sshDockerSource struct {
+	config DockerSSHConfig
+}
+
+type dockerStatsLine struct {
+	Name     string
+	CPUPerc  string
+	MemUsage string
+	MemPerc  string
+	NetIO    string
+	BlockIO  string
+	PIDs     string
+}
+
+// run runs command on the PCC host.
+func (s *sshDockerSource) run(command string) (out []byte, err error) {
+	args := []string{"-o", "BatchMode=yes"}
+	if s.config.Port != 0 {
+		args = append(args, "-p", fmt.Sprint(s.config.Port))
+	}
+	if s.config.KeyFile != "" {
+		args = append(args, "-i", s.config.KeyFile)
+	}
+	host := s.config.Host
+	if s.config.User != "" {
+		host = s.config.User + "@" + host
+	}
+	args = append(args, host, command)
+
+	out, err = exec.Command("ssh", args...).Output()
+	if ee, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("ssh %v: %v %s", host, err,
+			bytes.TrimSpace(ee.Stderr))
+	}
+	return
+}
+
+func (s *sshDockerSource) FDs() (fds map[string]int, err error) {
+	out, err := s.run(countFDs)
+	if err == nil {
+		fds = parseFDs(out)
+	}
+	return
+}
+
+func (s *sshDockerSource) Stats() (stats []dockerstats.Stats, err error) {
+	out, err := s.run("docker stats --no-stream --format '{{json .}}'")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		var l dockerStatsLine
+
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err = json.Unmarshal([]byte(line), &l); err != nil {
+			return
+		}
+		s := dockerstats.Stats{Container: l.Name, CPU: l.CPUPerc}
+		s.Memory.Raw = l.MemUsage
+		s.Memory.Percent = l.MemPerc
+		s.IO.Network = l.NetIO
+		s.IO.Block = l.BlockIO
+		fmt.Sscan(l.PIDs, &s.PIDs)
+		stats = append(stats, s)
+	}
+	return
+}
@@ -0,0 +1,48 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"runtime"
+	"testing"
+)
+
+func TestCountGoroutines(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
+	mux.HandleFunc("/debug/pprof/heap", func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Write([]byte("heap profile: 1: 2 [3: 4] @ heap/1048576\n"))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+	profile := s.URL + "/debug/pprof/goroutine?debug=1"
+
+	goroutines, err := countGoroutines(map[string]string{
+		"pccserver": profile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := goroutines["pccserver"]; n < 2 || n > runtime.NumGoroutine()+4 {
+		t.Errorf("%v goroutines, want about %v", n,
+			runtime.NumGoroutine())
+	}
+
+	for _, u := range []string{
+		s.URL + "/debug/pprof/missing",
+		s.URL + "/debug/pprof/heap?debug=1",
+	} {
+		if _, err = countGoroutines(map[string]string{
+			"pccserver": profile,
+			"monitor":   u,
+		}); err == nil {
+			t.Errorf("%v: no error", u)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const LEVEL_ERROR = "error"
+
+// ErrWaitTimeout is returned when no awaited notification was raised in
+// time.
+var ErrWaitTimeout = errors.New("timeout exceeded")
+
+// EventWaiter says which notification to wait for.  Only those raised
+// for NodeId or ClusterId, when set, and of Type are considered, Type
+// being left to PCC and so ignored by a NotificationHub.  The first
+// whose message matches one of Success ends the wait, one matching one
+// of Failure or, with FailOnError, of level error fails it.  Those
+// matching one of Progress are logged once per pattern.  A pattern is a
+// substring of the message, or a regular expression after REGEX_PREFIX.
+// With NodeId, a provision status of the node matching one of
+// ProvisionFailure fails the wait too.  The wait lasts Timeout and PCC is
+// polled every Period, FREQUENCY seconds by default.  Name, e.g. the
+// workflow, labels the wait in the metrics.
+type EventWaiter struct {
+	Name             string
+	NodeId           uint64
+	ClusterId        uint64
+	Type             string
+	Success          []string
+	Failure          []string
+	Progress         []string
+	ProvisionFailure []string
+	FailOnError      bool
+	Timeout          time.Duration
+	Period           time.Duration
+}
+
+// EventFailure is returned when the wait is failed by Notification.
+type EventFailure struct {
+	Notification Notification
+}
+
+func (e *EventFailure) Error() string {
+	return fmt.Sprintf("failure notification for %v: %v",
+		e.Notification.TargetId, e.Notification.Message)
+}
+
+// StatusFailure is returned when the wait is failed by the provision
+// status of its node.
+type StatusFailure struct {
+	NodeId uint64
+	Status string
+}
+
+func (e *StatusFailure) Error() string {
+	return fmt.Sprintf("node %v provision status: %v", e.NodeId, e.Status)
+}
+
+func (w EventWaiter) filter(from time.Time) (f NotificationFilter) {
+	f = NotificationFilter{Since: from, Type: w.Type}
+	if w.ClusterId == 0 {
+		f.TargetId = w.NodeId
+	} else if w.NodeId == 0 {
+		f.TargetId = w.ClusterId
+	}
+	return
+}
+
+func (w EventWaiter) target(n *Notification) bool {
+	if w.NodeId == 0 && w.ClusterId == 0 {
+		return true
+	}
+	return (w.NodeId != 0 && n.TargetId == w.NodeId) ||
+		(w.ClusterId != 0 && n.TargetId == w.ClusterId)
+}
+
+// matchAny returns the first of patterns, substrings or regular
+// expressions after REGEX_PREFIX, that matches msg.
+func matchAny(msg string, patterns []string) (pattern string, ok bool) {
+	for _, pattern = range patterns {
+		if matchMessage(msg, pattern) {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// checkEvent tells whether n ends the wait of w, with an *EventFailure if it
+// fails it, and logs the progress notifications.
+func (p *PccClient) checkEvent(w *EventWaiter, n *Notification) (done bool,
+	err error) {
+
+	if !w.target(n) {
+		return
+	}
+	fields := Fields{"node": n.TargetId}
+	if _, ok := matchAny(n.Message, w.Failure); ok ||
+		(w.FailOnError && n.Level == LEVEL_ERROR) {
+		p.logf(LOG_WARN, fields, "failure notification: %v", n.Message)
+		err = &EventFailure{Notification: *n}
+		done = true
+		return
+	}
+	if _, ok := matchAny(n.Message, w.Success); ok {
+		done = true
+		return
+	}
+	if pattern, ok := matchAny(n.Message, w.Progress); ok {
+		p.logf(LOG_INFO, fields, "notification: %v", n.Message)
+		for j := range w.Progress {
+			if w.Progress[j] == pattern {
+				w.Progress = append(w.Progress[:j],
+					w.Progress[j+1:]...)
+				break
+			}
+		}
+	}
+	return
+}
+
+// checkStatus fails the wait of w with a *StatusFailure if the provision
+// status of its node says so.
+func (p *PccClient) checkStatus(w *EventWaiter) (err error) {
+	if w.NodeId == 0 || len(w.ProvisionFailure) == 0 {
+		return
+	}
+	status, err := p.GetProvisionStatus(w.NodeId)
+	if err != nil {
+		err = fmt.Errorf("failed to get provision status: %w", err)
+		return
+	}
+	status = strings.Trim(status, `"`)
+	if _, ok := matchAny(status, w.ProvisionFailure); ok {
+		p.logf(LOG_WARN, Fields{"node": w.NodeId},
+			"failure provision status: %v", status)
+		err = &StatusFailure{NodeId: w.NodeId, Status: status}
+	}
+	return
+}
+
+// WaitForEvent looks through the notifications raised since from until
+// one ends the wait as w says, and returns it.  It polls PCC every
+// Period, or waits on the NotificationHub of p when one is started.  It
+// fails with an *EventFailure, a *StatusFailure, a wrapped ErrWaitTimeout
+// or the error of the client.
+func (p *PccClient) WaitForEvent(from time.Time, w EventWaiter) (
+	n Notification, err error) {
+
+	if w.Period == 0 {
+		w.Period = FREQUENCY * time.Second
+	}
+	w.Progress = append([]string(nil), w.Progress...)
+	start := p.Clock().Now()
+	deadline := start.Add(w.Timeout)
+	defer func() {
+		p.observeWait(w.Name, err, p.Since(start))
+	}()
+	timeout := func() error {
+		return fmt.Errorf("%w waiting %v for %q", ErrWaitTimeout,
+			w.Timeout, w.Success)
+	}
+
+	// subscribed before reading the history, so that nothing raised
+	// in between is missed
+	var sub *Subscription
+	if h := p.NotificationHub(); h != nil {
+		sub = h.Subscribe()
+		defer sub.Close()
+	}
+	seen := make(map[string]bool)
+	for {
+		var (
+			events []Notification
+			done   bool
+		)
+
+		it := p.IterNotifications(w.filter(from))
+		for it.Next() {
+			events = append(events, it.Notification())
+		}
+		if err = it.Err(); err != nil {
+			err = fmt.Errorf("failed to get notifications: %w", err)
+			return
+		}
+
+		// oldest first, so the first outcome raised wins
+		for i := len(events) - 1; i >= 0; i-- {
+			n = events[i]
+			seen[notificationKey(&n)] = true
+			if done, err = p.checkEvent(&w, &n); done {
+				return
+			}
+		}
+		n = Notification{}
+		if err = p.checkStatus(&w); err != nil {
+			return
+		}
+		if sub != nil {
+			break
+		}
+
+		left := -p.Since(deadline)
+		if left <= 0 {
+			err = timeout()
+			return
+		}
+		if left > w.Period {
+			left = w.Period
+		}
+		if err = p.Sleep(left); err != nil {
+			return
+		}
+	}
+
+	// woken up every Period to check the provision status, if needed
+	filter := w.filter(from)
+	check := func() <-chan time.Time {
+		left := -p.Since(deadline)
+		if w.NodeId != 0 && len(w.ProvisionFailure) > 0 &&
+			left > w.Period {
+			left = w.Period
+		}
+		return p.Clock().After(left)
+	}
+	wake := check()
+	for {
+		var done bool
+
+		n, err = sub.Next(p.Context(), wake)
+		if errors.Is(err, ErrWaitTimeout) {
+			n = Notification{}
+			if p.Since(deadline) >= 0 {
+				err = timeout()
+				return
+			}
+			if err = p.checkStatus(&w); err != nil {
+				return
+			}
+			wake = check()
+			continue
+		}
+		if err != nil {
+			n = Notification{}
+			return
+		}
+		if seen[notificationKey(&n)] || !filter.match(&n) {
+			continue
+		}
+		if done, err = p.checkEvent(&w, &n); done {
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build ignore
+// +build ignore
+
+// gen_catalog writes catalogs/default.yaml from pcc.DefaultCatalog.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+func main() {
+	b, err := pcc.DefaultCatalogYAML()
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join("..",
+			pcc.DEFAULT_CATALOG_DIR, "default.yaml"), b, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
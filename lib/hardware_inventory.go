@@ -25,10 +25,6 @@ func (p *PccClient) GetHardwareInventory() (hwInventory []HardwareInventory, err
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	if err = json.Unmarshal(resp.Data, &hwInventory); err != nil {
 		return
 	}
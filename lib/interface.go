@@ -83,15 +83,11 @@ func (p *PccClient) GetIfacesByNodeId(nodeId uint64) (ifaces []*InterfaceDetail,
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, &node)
-		if err != nil {
-			return
-		}
-		ifaces = node.Interfaces
+	err = json.Unmarshal(resp.Data, &node)
+	if err != nil {
 		return
 	}
-	err = fmt.Errorf("GetIfaceByNodeId failed: %v", resp.Error)
+	ifaces = node.Interfaces
 	return
 }
 
@@ -145,26 +141,12 @@ func (p *PccClient) SetIfaceApply(iface InterfaceRequest) (err error) {
 	if resp.Status == 200 {
 		return nil
 	}
-	endpoint = fmt.Sprintf("pccserver/interface/apply")
-	var jsonData = map[string]string{"nodeId": string(iface.NodeId)}
-	data, err = json.Marshal(jsonData)
-	if err != nil {
-		return
-	}
-	resp, _, err = p.pccGateway("POST", endpoint, data)
-	if err != nil {
-		return
-	}
-	if resp.Status == 200 {
-		return nil
-	}
-	return fmt.Errorf(resp.Message)
+	return p.ApplyIface(iface.NodeId)
 }
 
 func (p *PccClient) SetIface(iface InterfaceRequest) (err error) {
 	var (
 		data     []byte
-		resp     HttpResp
 		endpoint string
 	)
 	endpoint = fmt.Sprintf("pccserver/interface")
@@ -173,20 +155,13 @@ func (p *PccClient) SetIface(iface InterfaceRequest) (err error) {
 		err = fmt.Errorf("Iface format not valid")
 		return
 	}
-	resp, _, err = p.pccGateway("POST", endpoint, data)
-	if err != nil {
-		return
-	}
-	if resp.Status == 200 {
-		return
-	}
+	_, _, err = p.pccGateway("POST", endpoint, data)
 	return
 }
 
 func (p *PccClient) ApplyIface(nodeId uint64) (err error) {
 	var (
 		data     []byte
-		resp     HttpResp
 		endpoint string
 	)
 	endpoint = fmt.Sprintf("pccserver/interface/apply")
@@ -195,14 +170,10 @@ func (p *PccClient) ApplyIface(nodeId uint64) (err error) {
 	if err != nil {
 		return
 	}
-	resp, _, err = p.pccGateway("POST", endpoint, data)
+	_, _, err = p.pccGateway("POST", endpoint, data)
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		return
-	}
-	err = fmt.Errorf(resp.Error)
 	return
 }
 
@@ -210,7 +181,6 @@ func (p *PccClient) SetIfaceAdmin(nodeId uint64, ifaceId int64, upDown string) (
 	var (
 		ir       InterfaceRequest
 		data     []byte
-		resp     HttpResp
 		endpoint string
 	)
 
@@ -228,13 +198,9 @@ func (p *PccClient) SetIfaceAdmin(nodeId uint64, ifaceId int64, upDown string) (
 	if err != nil {
 		return
 	}
-	resp, _, err = p.pccGateway("POST", endpoint, data)
+	_, _, err = p.pccGateway("POST", endpoint, data)
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		return
-	}
-	err = fmt.Errorf(resp.Error)
 	return
 }
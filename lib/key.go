@@ -49,13 +49,14 @@ func (p *PccClient) UploadKey(filePath string, label string, fileType string, de
 		err = fmt.Errorf("Invalid security key type [%v]\n", fileType)
 		return
 	}
-	url := fmt.Sprintf("https://%v:9999/key-manager/keys/upload/%v/%v",
-		p.pccIp, fileType, label)
+	endpoint := fmt.Sprintf("key-manager/keys/upload/%v/%v", fileType,
+		label)
 
 	file, err := os.Open(filePath)
 	if err != nil {
 		return
 	}
+	defer file.Close()
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -73,46 +74,49 @@ func (p *PccClient) UploadKey(filePath string, label string, fileType string, de
 	if err != nil {
 		return
 	}
-	client := &http.Client{}
-	req, _ := http.NewRequest("POST", url, body)
-	req.Header.Add("Authorization", p.bearer)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	r, err := client.Do(req)
-	defer r.Body.Close()
+	return p.upload(endpoint, writer.FormDataContentType(), body.Bytes())
+}
+
+// upload posts a multipart form to the key manager.
+func (p *PccClient) upload(endpoint string, contentType string,
+	body []byte) (err error) {
+
+	var (
+		r    *http.Response
+		data []byte
+	)
+
+	if r, data, err = p.doRequest("POST", endpoint, contentType,
+		body); err != nil {
+		return
+	}
+	if r.StatusCode != 200 {
+		err = newAPIError("POST", endpoint, r, data)
+	}
 	return
 }
 
 func (p *PccClient) DeleteKey(label string) (err error) {
 	var (
-		resp     HttpResp
 		endpoint string
 	)
 	endpoint = fmt.Sprintf("key-manager/keys/%v", label)
-	resp, _, err = p.pccSecurity("DELETE", endpoint, nil)
+	_, _, err = p.pccSecurity("DELETE", endpoint, nil)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	return
 }
 
 func (p *PccClient) DeleteKeyById(id uint64) (err error) {
 	var (
-		resp     HttpResp
 		endpoint string
 	)
 	endpoint = fmt.Sprintf("key-manager/keys/%v", id)
-	resp, _, err = p.pccSecurity("DELETE", endpoint, nil)
+	_, _, err = p.pccSecurity("DELETE", endpoint, nil)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	return
 }
 
@@ -126,10 +130,6 @@ func (p *PccClient) GetSecurityKeys() (secKeys []SecurityKey, err error) {
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	err = json.Unmarshal(resp.Data, &secKeys)
 	return
 }
@@ -144,10 +144,6 @@ func (p *PccClient) GetSecurityKey(alias string) (secKey SecurityKey, err error)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	err = json.Unmarshal(resp.Data, &secKey)
 	return
 }
@@ -174,13 +170,13 @@ func (p *PccClient) FindSecurityKey(alias string) (exist bool, secKey SecurityKe
 
 func (p *PccClient) UploadCert(filePath string, label string, description string) (err error) {
 
-	url := fmt.Sprintf("https://%s:9999/key-manager/certificates/upload/%v",
-		p.pccIp, label)
+	endpoint := fmt.Sprintf("key-manager/certificates/upload/%v", label)
 
 	file, err := os.Open(filePath)
 	if err != nil {
 		return
 	}
+	defer file.Close()
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -198,13 +194,7 @@ func (p *PccClient) UploadCert(filePath string, label string, description string
 	if err != nil {
 		return
 	}
-	client := &http.Client{}
-	req, _ := http.NewRequest("POST", url, body)
-	req.Header.Add("Authorization", p.bearer)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	r, err := client.Do(req)
-	defer r.Body.Close()
-	return
+	return p.upload(endpoint, writer.FormDataContentType(), body.Bytes())
 }
 
 func (p *PccClient) FindCertificate(alias string) (exist bool, certificate Certificate, err error) {
@@ -228,8 +218,6 @@ func (p *PccClient) FindCertificate(alias string) (exist bool, certificate Certi
 				return
 			}
 		}
-	} else {
-		err = fmt.Errorf("%v", resp.Error)
 	}
 
 	return
@@ -245,15 +233,7 @@ func (p *PccClient) GetCertificates() (certificates []Certificate, err error) {
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, &certificates)
-		if err != nil {
-			return
-		}
-	} else {
-		err = fmt.Errorf("%v", resp.Error)
-	}
-
+	err = json.Unmarshal(resp.Data, &certificates)
 	return
 }
 
@@ -279,18 +259,13 @@ func (p *PccClient) GetCertificate(id uint64) (certificate Certificate, err erro
 func (p *PccClient) DeleteCertificate(id uint64) (err error) {
 	var (
 		endpoint string
-		resp     HttpResp
 	)
 
 	endpoint = fmt.Sprintf("key-manager/certificates/%v", id)
-	resp, _, err = p.pccSecurity("DELETE", endpoint, nil)
+	_, _, err = p.pccSecurity("DELETE", endpoint, nil)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 
 	return
 }
@@ -40,21 +40,14 @@ type K8sCluster struct {
 
 func (p *PccClient) CreateKubernetes(k8sReq K8sClusterRequest) (err error) {
 	var (
-		body []byte
 		data []byte
-		resp HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/kubernetes")
 	if data, err = json.Marshal(k8sReq); err != nil {
 		err = fmt.Errorf("invalid struct for K8s creation")
 		return
 	}
-	if resp, body, err = p.pccGateway("POST", endpoint, data); err != nil {
-		err = fmt.Errorf("%v\n%v\n", string(body), err)
-		return
-	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("K8s creation failed:\n%v\n", string(body))
+	if _, _, err = p.pccGateway("POST", endpoint, data); err != nil {
 		return
 	}
 	return
@@ -62,16 +55,10 @@ func (p *PccClient) CreateKubernetes(k8sReq K8sClusterRequest) (err error) {
 
 func (p *PccClient) GetKubernetes() (clusters []K8sCluster, err error) {
 	var (
-		body []byte
 		resp HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/kubernetes")
-	if resp, body, err = p.pccGateway("GET", endpoint, nil); err != nil {
-		err = fmt.Errorf("%v\n%v\n", string(body), err)
-		return
-	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("K8s get failed:\n%v\n", string(body))
+	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
 	err = json.Unmarshal(resp.Data, &clusters)
@@ -80,16 +67,10 @@ func (p *PccClient) GetKubernetes() (clusters []K8sCluster, err error) {
 
 func (p *PccClient) GetKubernetesId(id uint64) (cluster K8sCluster, err error) {
 	var (
-		body []byte
 		resp HttpResp
 	)
 	endpoint := fmt.Sprintf("pccserver/kubernetes/%v", id)
-	if resp, body, err = p.pccGateway("GET", endpoint, nil); err != nil {
-		err = fmt.Errorf("%v\n%v\n", string(body), err)
-		return
-	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("K8s get failed:\n%v\n", string(body))
+	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
 	err = json.Unmarshal(resp.Data, &cluster)
@@ -140,8 +121,6 @@ func (p *PccClient) DeleteKubernetes(id uint64, force bool) (err error) {
 
 	var (
 		data []byte
-		body []byte
-		resp HttpResp
 	)
 	type delK8Req struct {
 		forceRemove bool
@@ -153,13 +132,8 @@ func (p *PccClient) DeleteKubernetes(id uint64, force bool) (err error) {
 	}
 
 	endpoint := fmt.Sprintf("pccserver/kubernetes/%v", id)
-	resp, body, err = p.pccGateway("DELETE", endpoint, data)
+	_, _, err = p.pccGateway("DELETE", endpoint, data)
 	if err != nil {
-		err = fmt.Errorf("%v\n%v\n", string(body), err)
-		return
-	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("K8s delete failed:\n%v\n", string(body))
 		return
 	}
 	return
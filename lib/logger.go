@@ -0,0 +1,158 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type LogLevel int
+
+const (
+	LOG_DEBUG LogLevel = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l LogLevel) String() string {
+	if l >= 0 && int(l) < len(logLevelNames) {
+		return logLevelNames[l]
+	}
+	return fmt.Sprintf("LEVEL%d", int(l))
+}
+
+// ParseLogLevel accepts the names printed by LogLevel.String in any case,
+// "" being LOG_INFO.
+func ParseLogLevel(name string) (level LogLevel, err error) {
+	if name == "" {
+		return LOG_INFO, nil
+	}
+	for i, n := range logLevelNames {
+		if strings.EqualFold(n, name) {
+			return LogLevel(i), nil
+		}
+	}
+	err = fmt.Errorf("invalid log level %v", name)
+	return
+}
+
+// Fields qualify a log message, e.g. with the node, endpoint or phase it
+// is about.
+type Fields map[string]interface{}
+
+// Logger receives the messages of lib.
+type Logger interface {
+	Log(level LogLevel, msg string, fields Fields)
+}
+
+// formatLog writes "LEVEL msg key=value ...", the keys sorted.
+func formatLog(level LogLevel, msg string, fields Fields) string {
+	var b strings.Builder
+
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %v=%v", k, fields[k])
+	}
+	return b.String()
+}
+
+// WriterLogger writes the messages from Level up to W, one per line.
+type WriterLogger struct {
+	Level LogLevel
+	mu    sync.Mutex
+	w     io.Writer
+}
+
+func NewWriterLogger(w io.Writer, level LogLevel) *WriterLogger {
+	return &WriterLogger{Level: level, w: w}
+}
+
+func (l *WriterLogger) Log(level LogLevel, msg string, fields Fields) {
+	if level < l.Level {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, formatLog(level, msg, fields))
+}
+
+// FuncLogger passes the messages from Level up to Logf, e.g. the Logf of
+// a *testing.T so that the output of each subtest is kept together.
+type FuncLogger struct {
+	Level LogLevel
+	Logf  func(format string, args ...interface{})
+}
+
+func (l FuncLogger) Log(level LogLevel, msg string, fields Fields) {
+	if level < l.Level {
+		return
+	}
+	l.Logf("%s", formatLog(level, msg, fields))
+}
+
+// DefaultLogger is used by clients with no logger of their own and by
+// the parts of lib that don't belong to a client.
+var DefaultLogger Logger = NewWriterLogger(os.Stdout, LOG_INFO)
+
+// WithLogger returns a copy of p that logs to l.
+func (p *PccClient) WithLogger(l Logger) *PccClient {
+	p2 := *p
+	p2.logger = l
+	return &p2
+}
+
+// WithFields returns a copy of p that adds fields to all its messages.
+func (p *PccClient) WithFields(fields Fields) *PccClient {
+	p2 := *p
+	p2.fields = make(Fields, len(p.fields)+len(fields))
+	for k, v := range p.fields {
+		p2.fields[k] = v
+	}
+	for k, v := range fields {
+		p2.fields[k] = v
+	}
+	return &p2
+}
+
+// Logger returns where the messages of p go.
+func (p *PccClient) Logger() Logger {
+	if p.logger != nil {
+		return p.logger
+	}
+	return DefaultLogger
+}
+
+// logf logs a message qualified by the fields of p and fields.
+func (p *PccClient) logf(level LogLevel, fields Fields, format string,
+	args ...interface{}) {
+
+	all := fields
+	if len(p.fields) > 0 {
+		all = make(Fields, len(p.fields)+len(fields))
+		for k, v := range p.fields {
+			all[k] = v
+		}
+		for k, v := range fields {
+			all[k] = v
+		}
+	}
+	p.Logger().Log(level, fmt.Sprintf(format, args...), all)
+}
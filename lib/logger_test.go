@@ -0,0 +1,79 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWriterLoggerLevel(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewWriterLogger(&b, LOG_WARN)
+	l.Log(LOG_DEBUG, "debug", nil)
+	l.Log(LOG_INFO, "info", nil)
+	l.Log(LOG_WARN, "warn", nil)
+	l.Log(LOG_ERROR, "error", Fields{"node": 3})
+	if want := "WARN warn\nERROR error node=3\n"; b.String() != want {
+		t.Errorf("logged %q, want %q", b.String(), want)
+	}
+}
+
+func TestFuncLogger(t *testing.T) {
+	var logged []string
+
+	p := &PccClient{}
+	p = p.WithLogger(FuncLogger{
+		Level: LOG_INFO,
+		Logf: func(format string, args ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+		},
+	}).WithFields(Fields{"phase": "installLLDP", "node": 1})
+	p.logf(LOG_DEBUG, nil, "not logged")
+	p.logf(LOG_INFO, Fields{"node": 2, "endpoint": "pccserver/node"},
+		"got %v nodes", 3)
+	p.logf(LOG_WARN, nil, "retrying")
+
+	want := []string{
+		"INFO got 3 nodes endpoint=pccserver/node node=2 " +
+			"phase=installLLDP",
+		"WARN retrying node=1 phase=installLLDP",
+	}
+	if !reflect.DeepEqual(logged, want) {
+		t.Errorf("logged %q, want %q", logged, want)
+	}
+}
+
+func TestWithFieldsCopies(t *testing.T) {
+	p := (&PccClient{}).WithFields(Fields{"phase": "a"})
+	p2 := p.WithFields(Fields{"phase": "b", "node": 1})
+	if want := (Fields{"phase": "a"}); !reflect.DeepEqual(p.fields, want) {
+		t.Errorf("fields %v changed by a copy, want %v", p.fields, want)
+	}
+	if want := (Fields{"phase": "b", "node": 1}); !reflect.DeepEqual(
+		p2.fields, want) {
+		t.Errorf("fields of the copy %v, want %v", p2.fields, want)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for name, want := range map[string]LogLevel{
+		"":      LOG_INFO,
+		"debug": LOG_DEBUG,
+		"WARN":  LOG_WARN,
+		"Error": LOG_ERROR,
+	} {
+		if level, err := ParseLogLevel(name); err != nil ||
+			level != want {
+			t.Errorf("%q: %v, %v, want %v", name, level, err, want)
+		}
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Error("no error for verbose")
+	}
+}
@@ -11,6 +11,35 @@ import (
 	"github.com/platinasystems/tiles/pccserver/maas/models"
 )
 
+const MAAS_INSTALL_TIMEOUT = 300
+
+var MAAS_INSTALL_MILESTONES = []NamedMessage{
+	{Name: "bare-metal role started", Message: "[MAAS] Starting Bare-metal Role "},
+	{Name: "dependencies in progress", Message: "Bare Metal Dependencies in progress"},
+	{Name: "dependencies completed", Message: "Bare Metal Dependencies playbook completed"},
+	{Name: "image repository in progress", Message: "Bare Metal Image Repository in progress"},
+	{Name: "source media updated", Message: "Updated Platina Utility Linux source media"},
+	{Name: "image repository completed", Message: "Bare Metal Image Repository playbook completed"},
+	{Name: "deployment role installed", Message: "[MAAS] Bare-metal deployment Role has been installed"},
+	{Name: "multitenancy in progress", Message: "Bare Metal Multitenancy in progress"},
+	{Name: "ROOT repository updated", Message: "Updating private deployment repository for tenant 'ROOT'"},
+	{Name: "multitenancy completed", Message: "Bare Metal Multitenancy playbook completed"},
+}
+
+// MaasInstallSequence returns the milestones of the installation of MaaS
+// on a node, any error notified for the node failing it.
+func (c *Catalog) MaasInstallSequence(nodeId uint64) (s Sequence,
+	err error) {
+
+	name := fmt.Sprintf("MaaS install on %v", nodeId)
+	if s, err = c.Sequence(MAAS_INSTALL_EVENT, name, nil); err != nil {
+		return
+	}
+	s.NodeId = nodeId
+	s.FailOnError = true
+	return
+}
+
 type MaasRequest struct {
 	models.MaasRequest
 }
@@ -18,7 +47,6 @@ type MaasRequest struct {
 func (p *PccClient) MaasDeploy(maasReq MaasRequest) (err error) {
 	var (
 		data []byte
-		resp HttpResp
 	)
 
 	endpoint := fmt.Sprintf("maas/deployments")
@@ -26,11 +54,7 @@ func (p *PccClient) MaasDeploy(maasReq MaasRequest) (err error) {
 		return
 	}
 
-	if resp, _, err = p.pccGateway("POST", endpoint, data); err != nil {
-		return
-	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
+	if _, _, err = p.pccGateway("POST", endpoint, data); err != nil {
 		return
 	}
 	return
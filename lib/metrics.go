@@ -0,0 +1,450 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	METRICS_NAMESPACE = "pcc_blackbox"
+
+	METRIC_COUNTER   = "counter"
+	METRIC_GAUGE     = "gauge"
+	METRIC_HISTOGRAM = "histogram"
+)
+
+var (
+	// buckets of the API request latencies, in seconds
+	DEFAULT_REQUEST_BUCKETS = []float64{.01, .025, .05, .1, .25, .5, 1,
+		2.5, 5, 10, 30, 60}
+	// buckets of the notification waits, in seconds
+	DEFAULT_WAIT_BUCKETS = []float64{1, 5, 15, 30, 60, 120, 300, 600,
+		1200, 1800, 3600, 7200}
+)
+
+// MetricsConfig says where the metrics of a run are exposed: served in
+// the Prometheus text format at /metrics on Listen, e.g. ":9200", and
+// written in that format to File after each phase and at the end, e.g.
+// for the textfile collector of node_exporter.
+type MetricsConfig struct {
+	Listen string
+	File   string
+}
+
+// Labels are the labels of a series.
+type Labels map[string]string
+
+type metricSeries struct {
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// Metrics holds the counters, gauges and histograms of a run, all named
+// after METRICS_NAMESPACE, and writes them in the Prometheus text
+// exposition format.  A nil *Metrics records nothing.
+type Metrics struct {
+	config MetricsConfig
+	server *http.Server
+
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+// StartMetrics returns the metrics of a run, served on config.Listen when
+// set.
+func StartMetrics(config MetricsConfig) (m *Metrics, err error) {
+	m = NewMetrics()
+	m.config = config
+	if config.Listen == "" {
+		return
+	}
+
+	var l net.Listener
+
+	if l, err = net.Listen("tcp", config.Listen); err != nil {
+		err = fmt.Errorf("metrics: %v", err)
+		m = nil
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	m.server = &http.Server{Handler: mux}
+	go m.server.Serve(l)
+	return
+}
+
+// Flush writes the metrics to the File of their config, if any.
+func (m *Metrics) Flush() error {
+	if m == nil || m.config.File == "" {
+		return nil
+	}
+	return m.WriteFile(m.config.File)
+}
+
+// Close stops serving the metrics and flushes them.
+func (m *Metrics) Close() (err error) {
+	if m == nil {
+		return
+	}
+	if m.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			5*time.Second)
+		defer cancel()
+		m.server.Shutdown(ctx)
+	}
+	return m.Flush()
+}
+
+// Add adds v to the counter name.
+func (m *Metrics) Add(name string, help string, labels Labels, v float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, s := m.series(name, help, METRIC_COUNTER, nil, labels)
+	s.value += v
+}
+
+// Set sets the gauge name to v.
+func (m *Metrics) Set(name string, help string, labels Labels, v float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, s := m.series(name, help, METRIC_GAUGE, nil, labels)
+	s.value = v
+}
+
+// Observe adds v to the histogram name, of upper bounds buckets.
+func (m *Metrics) Observe(name string, help string, buckets []float64,
+	labels Labels, v float64) {
+
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, s := m.series(name, help, METRIC_HISTOGRAM, buckets, labels)
+	for i, le := range f.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (m *Metrics) series(name string, help string, kind string,
+	buckets []float64, labels Labels) (f *metricFamily, s *metricSeries) {
+
+	name = METRICS_NAMESPACE + "_" + name
+	if f = m.families[name]; f == nil {
+		f = &metricFamily{
+			name:    name,
+			help:    help,
+			kind:    kind,
+			buckets: buckets,
+			series:  make(map[string]*metricSeries),
+		}
+		m.families[name] = f
+	}
+	key := formatLabels(labels)
+	if s = f.series[key]; s == nil {
+		s = &metricSeries{}
+		if kind == METRIC_HISTOGRAM {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return
+}
+
+// labelEscaper escapes a label value as the exposition format wants:
+// only backslash, double quote and line feed, all else as is.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel quotes value as a label value of the exposition format.
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+// formatLabels formats labels as in the exposition format, by name.
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + quoteLabel(labels[name])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel adds name=value to the formatted labels.
+func withLabel(labels string, name string, value string) string {
+	pair := name + "=" + quoteLabel(value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (n int64, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := m.families[name]
+		fmt.Fprintf(&b, "# HELP %v %v\n", name, f.help)
+		fmt.Fprintf(&b, "# TYPE %v %v\n", name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != METRIC_HISTOGRAM {
+				fmt.Fprintf(&b, "%v%v %v\n", name, key,
+					formatMetricValue(s.value))
+				continue
+			}
+			for i, le := range f.buckets {
+				fmt.Fprintf(&b, "%v_bucket%v %v\n", name,
+					withLabel(key, "le", formatMetricValue(le)),
+					s.counts[i])
+			}
+			fmt.Fprintf(&b, "%v_bucket%v %v\n", name,
+				withLabel(key, "le", "+Inf"), s.count)
+			fmt.Fprintf(&b, "%v_sum%v %v\n", name, key,
+				formatMetricValue(s.sum))
+			fmt.Fprintf(&b, "%v_count%v %v\n", name, key, s.count)
+		}
+	}
+	written, err := w.Write(b.Bytes())
+	n = int64(written)
+	return
+}
+
+// ServeHTTP serves the metrics to Prometheus.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteFile writes the metrics to file, replacing it at once so that a
+// collector never reads half of it.
+func (m *Metrics) WriteFile(file string) (err error) {
+	var b bytes.Buffer
+
+	if _, err = m.WriteTo(&b); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file),
+		"."+filepath.Base(file))
+	if err != nil {
+		return
+	}
+	if _, err = tmp.Write(b.Bytes()); err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return
+}
+
+// ExportMetrics has p, and the copies made of it, count its requests,
+// retries and waits in m.
+func (p *PccClient) ExportMetrics(m *Metrics) {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	p.session.metrics = m
+}
+
+// Metrics returns the metrics p counts in, nil if none.
+func (p *PccClient) Metrics() *Metrics {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	return p.session.metrics
+}
+
+// metricsEndpoint is endPoint without its query and with its ids
+// replaced, so that the requests of an endpoint add up.
+func metricsEndpoint(endPoint string) string {
+	if i := strings.IndexByte(endPoint, '?'); i >= 0 {
+		endPoint = endPoint[:i]
+	}
+	parts := strings.Split(strings.Trim(endPoint, "/"), "/")
+	for i, part := range parts {
+		if isMetricsId(part) {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// isMetricsId tells whether a part of a path is a number or a UUID.
+func isMetricsId(part string) bool {
+	if part == "" {
+		return false
+	}
+	if _, err := strconv.ParseUint(part, 10, 64); err == nil {
+		return true
+	}
+	if len(part) != 36 {
+		return false
+	}
+	for i, c := range part {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case !strings.ContainsRune("0123456789abcdefABCDEF", c):
+			return false
+		}
+	}
+	return true
+}
+
+// observeRequest counts a request sent to endPoint, which got the status
+// of r or failed with err, and took d.
+func (p *PccClient) observeRequest(op string, endPoint string,
+	r *http.Response, err error, d time.Duration) {
+
+	m := p.Metrics()
+	if m == nil {
+		return
+	}
+	endPoint = metricsEndpoint(endPoint)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(r.StatusCode)
+	}
+	m.Add("api_requests_total", "PCC requests, by endpoint and status.",
+		Labels{"method": op, "endpoint": endPoint, "code": code}, 1)
+	m.Observe("api_request_duration_seconds",
+		"Latency of the PCC requests, retries included.",
+		DEFAULT_REQUEST_BUCKETS,
+		Labels{"method": op, "endpoint": endPoint}, d.Seconds())
+}
+
+// observeRetry counts a request to endPoint sent again.
+func (p *PccClient) observeRetry(op string, endPoint string) {
+	p.Metrics().Add("api_retries_total", "PCC requests sent again.",
+		Labels{"method": op, "endpoint": metricsEndpoint(endPoint)}, 1)
+}
+
+// observeWait counts a wait for a notification named name, that ended
+// with err after d.
+func (p *PccClient) observeWait(name string, err error, d time.Duration) {
+	p.Metrics().Observe("wait_duration_seconds",
+		"Waits for notifications, by name and outcome.",
+		DEFAULT_WAIT_BUCKETS,
+		Labels{"wait": name, "outcome": waitStatus(err)}, d.Seconds())
+}
+
+// ObservePhase counts a phase of the test that took d.
+func (m *Metrics) ObservePhase(phase string, passed bool, d time.Duration) {
+	result := "pass"
+	if !passed {
+		result = "fail"
+	}
+	m.Set("phase_duration_seconds", "Duration of the last run of a phase.",
+		Labels{"phase": phase}, d.Seconds())
+	m.Add("phase_runs_total", "Runs of a phase, by result.",
+		Labels{"phase": phase, "result": result}, 1)
+}
+
+// observeDockerSample sets the gauges of the container of s.
+func (m *Metrics) observeDockerSample(s *DockerSample) {
+	if m == nil {
+		return
+	}
+	labels := Labels{"container": s.Container}
+	m.Set("container_cpu_percent",
+		"CPU of a container at the last sample, in percent of one CPU.",
+		labels, s.CPU)
+	m.Set("container_memory_bytes",
+		"Memory of a container at the last sample.", labels,
+		float64(s.Memory))
+	m.Set("container_memory_limit_bytes",
+		"Memory limit of a container at the last sample.", labels,
+		float64(s.MemoryLimit))
+	m.Set("container_pids", "Pids of a container at the last sample.",
+		labels, float64(s.PIDs))
+	if s.FDs != 0 {
+		m.Set("container_fds",
+			"Files open by the main process of a container.", labels,
+			float64(s.FDs))
+	}
+	if s.Goroutines != 0 {
+		m.Set("container_goroutines",
+			"Goroutines of a Go container at the last sample.",
+			labels, float64(s.Goroutines))
+	}
+	m.Add("container_samples_total", "Samples of a container.", labels, 1)
+}
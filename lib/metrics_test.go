@@ -0,0 +1,149 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func metricsText(t *testing.T, m *Metrics) string {
+	t.Helper()
+	var b bytes.Buffer
+
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestMetricsCounterGauge(t *testing.T) {
+	m := NewMetrics()
+	m.Add("api_retries_total", "PCC requests sent again.",
+		Labels{"method": "GET", "endpoint": "pccserver/node"}, 1)
+	m.Add("api_retries_total", "PCC requests sent again.",
+		Labels{"endpoint": "pccserver/node", "method": "GET"}, 2)
+	m.Add("api_retries_total", "PCC requests sent again.",
+		Labels{"method": "DELETE", "endpoint": "pccserver/node/:id"}, 1)
+	m.Set("phase_duration_seconds", "Duration of the last run of a phase.",
+		Labels{"phase": "installMAAS"}, 12.5)
+	m.Set("phase_duration_seconds", "Duration of the last run of a phase.",
+		Labels{"phase": "installMAAS"}, 0.25)
+	m.Set("up", "Whether the run is on.", nil, 1)
+
+	want := `# HELP pcc_blackbox_api_retries_total PCC requests sent again.
+# TYPE pcc_blackbox_api_retries_total counter
+pcc_blackbox_api_retries_total{endpoint="pccserver/node",method="GET"} 3
+pcc_blackbox_api_retries_total{endpoint="pccserver/node/:id",method="DELETE"} 1
+# HELP pcc_blackbox_phase_duration_seconds Duration of the last run of a phase.
+# TYPE pcc_blackbox_phase_duration_seconds gauge
+pcc_blackbox_phase_duration_seconds{phase="installMAAS"} 0.25
+# HELP pcc_blackbox_up Whether the run is on.
+# TYPE pcc_blackbox_up gauge
+pcc_blackbox_up 1
+`
+	if got := metricsText(t, m); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestMetricsHistogram(t *testing.T) {
+	m := NewMetrics()
+	for _, v := range []float64{0.5, 3, 10} {
+		m.Observe("wait_duration_seconds", "Waits for notifications.",
+			[]float64{1, 5}, Labels{"wait": "lldpInstall"}, v)
+	}
+
+	want := `# HELP pcc_blackbox_wait_duration_seconds Waits for notifications.
+# TYPE pcc_blackbox_wait_duration_seconds histogram
+pcc_blackbox_wait_duration_seconds_bucket{wait="lldpInstall",le="1"} 1
+pcc_blackbox_wait_duration_seconds_bucket{wait="lldpInstall",le="5"} 2
+pcc_blackbox_wait_duration_seconds_bucket{wait="lldpInstall",le="+Inf"} 3
+pcc_blackbox_wait_duration_seconds_sum{wait="lldpInstall"} 13.5
+pcc_blackbox_wait_duration_seconds_count{wait="lldpInstall"} 3
+`
+	if got := metricsText(t, m); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	m := NewMetrics()
+	m.Set("phase_duration_seconds", "Duration of the last run of a phase.",
+		Labels{"phase": "C:\\pcc \"main\"\nnext\ttab é\x00"}, 1)
+
+	want := `# HELP pcc_blackbox_phase_duration_seconds Duration of the last run of a phase.
+# TYPE pcc_blackbox_phase_duration_seconds gauge
+pcc_blackbox_phase_duration_seconds{phase="C:\\pcc \"main\"\nnext` +
+		"\ttab é\x00" + `"} 1
+`
+	if got := metricsText(t, m); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestMetricsFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "pcc-blackbox.prom")
+
+	m, err := StartMetrics(MetricsConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ObservePhase("installLLDP", true, 0)
+	if err = m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP pcc_blackbox_phase_duration_seconds Duration of the last run of a phase.
+# TYPE pcc_blackbox_phase_duration_seconds gauge
+pcc_blackbox_phase_duration_seconds{phase="installLLDP"} 0
+# HELP pcc_blackbox_phase_runs_total Runs of a phase, by result.
+# TYPE pcc_blackbox_phase_runs_total counter
+pcc_blackbox_phase_runs_total{phase="installLLDP",result="pass"} 1
+`
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("flushed\n%v\nwant\n%v", string(b), want)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("%v files left in %v, want only %v", len(files), dir,
+			filepath.Base(file))
+	}
+
+	var none *Metrics
+	none.ObservePhase("installLLDP", false, 0)
+	if err = none.Flush(); err != nil {
+		t.Errorf("flushing nil metrics: %v", err)
+	}
+	if err = NewMetrics().Flush(); err != nil {
+		t.Errorf("flushing metrics with no file: %v", err)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	for endPoint, want := range map[string]string{
+		"pccserver/node":                       "pccserver/node",
+		"pccserver/node/12/apps?page=2":        "pccserver/node/:id/apps",
+		"/key-manager/keys/describe/":          "key-manager/keys/describe",
+		"pccserver/kubernetes/" + testUUID:     "pccserver/kubernetes/:id",
+		"pccserver/kubernetes/" + testUUID[1:]: "pccserver/kubernetes/" + testUUID[1:],
+	} {
+		if got := metricsEndpoint(endPoint); got != want {
+			t.Errorf("%v: %v, want %v", endPoint, got, want)
+		}
+	}
+}
+
+const testUUID = "123e4567-e89b-12d3-a456-426614174000"
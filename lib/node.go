@@ -11,6 +11,13 @@ import (
 	"github.com/platinasystems/tiles/pccserver/models"
 )
 
+// provision statuses of a node that end a workflow in failure
+const (
+	PROVISION_STATUS_ADD_FAILED     = "Add node failed"
+	PROVISION_STATUS_UPDATE_FAILED  = "Update node failed"
+	PROVISION_STATUS_REIMAGE_FAILED = "reimage failed"
+)
+
 type NodeAvailability struct {
 	models.NodeAvailability
 }
@@ -44,10 +51,6 @@ func (p *PccClient) GetNodesDetail() (nodes []*NodeDetail, err error) {
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	if err = json.Unmarshal(resp.Data, &nodes); err != nil {
 		return
 	}
@@ -63,10 +66,6 @@ func (p *PccClient) GetNodesWithKubernetes() (nodes []*NodeWithKubernetes,
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	if err = json.Unmarshal(resp.Data, &nodes); err != nil {
 		return
 	}
@@ -80,10 +79,6 @@ func (p *PccClient) GetNodesId(id uint64) (node NodeDetail, err error) {
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	if err = json.Unmarshal(resp.Data, &node); err != nil {
 		return
 	}
@@ -116,11 +111,7 @@ func (p *PccClient) GetProvisionStatus(id uint64) (status string, err error) {
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		status = string(resp.Data) // status has double quotes
-		return
-	}
-	err = fmt.Errorf("%v", resp.Error)
+	status = string(resp.Data) // status has double quotes
 	return
 }
 
@@ -132,14 +123,10 @@ func (p *PccClient) GetNodeSummary(id uint64, node *NodeWithKubernetes) (err err
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, node)
-		if err != nil {
-			return
-		}
+	err = json.Unmarshal(resp.Data, node)
+	if err != nil {
 		return
 	}
-	err = fmt.Errorf("%v", resp.Message)
 	return
 }
 
@@ -185,14 +172,10 @@ func (p *PccClient) AddNode(hostIp string, pccManaged bool) (node NodeWithKubern
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, &node)
-		if err != nil {
-			return
-		}
+	err = json.Unmarshal(resp.Data, &node)
+	if err != nil {
 		return
 	}
-	err = fmt.Errorf("%v", resp.Message)
 	return
 }
 
@@ -213,31 +196,22 @@ func (p *PccClient) UpdateNode(addReq NodeWithKubernetes) (node NodeWithKubernet
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, &node)
-		if err != nil {
-			return
-		}
+	err = json.Unmarshal(resp.Data, &node)
+	if err != nil {
 		return
 	}
-	err = fmt.Errorf("%v", resp.Message)
 	return
 }
 
 func (p *PccClient) DelNode(id uint64) (err error) {
 	var (
 		endpoint string
-		resp     HttpResp
 	)
 
 	endpoint = fmt.Sprintf("pccserver/node/%v", id)
-	resp, _, err = p.pccGateway("DELETE", endpoint, nil)
+	_, _, err = p.pccGateway("DELETE", endpoint, nil)
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		return
-	}
-	err = fmt.Errorf("%v", resp.Message)
 	return
 }
@@ -0,0 +1,138 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NODE_WAIT_SUCCESS = "success"
+	NODE_WAIT_FAILURE = "failure"
+	NODE_WAIT_TIMEOUT = "timeout"
+	NODE_WAIT_ERROR   = "error"
+)
+
+// NodeResult is the outcome of the wait for one node: the notification
+// that ended it, if any, and how long it took.
+type NodeResult struct {
+	NodeId       uint64
+	Status       string
+	Notification Notification
+	Duration     time.Duration
+	Err          error
+}
+
+// NodeResults are the outcomes of WaitForNodes, in the order of its ids.
+type NodeResults []NodeResult
+
+// Failed returns the results of the nodes that didn't succeed.
+func (r NodeResults) Failed() (failed NodeResults) {
+	for _, nr := range r {
+		if nr.Status != NODE_WAIT_SUCCESS {
+			failed = append(failed, nr)
+		}
+	}
+	return
+}
+
+// Err returns an error listing the nodes that didn't succeed, if any.
+func (r NodeResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(failed))
+	for i, nr := range failed {
+		msgs[i] = fmt.Sprintf("node %v: %v", nr.NodeId, nr.Err)
+	}
+	return fmt.Errorf("%v/%v nodes failed: %v", len(failed), len(r),
+		strings.Join(msgs, "; "))
+}
+
+// String lists the nodes with their outcome, e.g.
+//
+//	3 nodes: 2 success, 1 timeout
+//	  node 12  success  1m5s
+//	  node 13  timeout  timeout exceeded ...
+func (r NodeResults) String() string {
+	var b strings.Builder
+
+	count := make(map[string]int)
+	for _, nr := range r {
+		count[nr.Status]++
+	}
+	fmt.Fprintf(&b, "%v nodes:", len(r))
+	sep := " "
+	for _, status := range []string{NODE_WAIT_SUCCESS, NODE_WAIT_FAILURE,
+		NODE_WAIT_TIMEOUT, NODE_WAIT_ERROR} {
+		if count[status] > 0 {
+			fmt.Fprintf(&b, "%v%v %v", sep, count[status], status)
+			sep = ", "
+		}
+	}
+	for _, nr := range r {
+		fmt.Fprintf(&b, "\n  node %-4v %-8v ", nr.NodeId, nr.Status)
+		if nr.Status == NODE_WAIT_SUCCESS {
+			fmt.Fprint(&b, nr.Duration)
+		} else {
+			fmt.Fprint(&b, nr.Err)
+		}
+	}
+	return b.String()
+}
+
+// WaitForNodes waits as w says on each of ids at once, w.NodeId being
+// set to each in turn, until all are done or w.Timeout, shared by all,
+// runs out.  Start a NotificationHub first so that the waits share one
+// stream rather than polling each.
+func (p *PccClient) WaitForNodes(from time.Time, ids []uint64,
+	w EventWaiter) (r NodeResults) {
+
+	var wg sync.WaitGroup
+
+	r = make(NodeResults, len(ids))
+	start := p.Clock().Now()
+	deadline := start.Add(w.Timeout)
+	for i, id := range ids {
+		nw := w
+		nw.NodeId = id
+		nw.ClusterId = 0
+		r[i].NodeId = id
+		wg.Add(1)
+		go func(nr *NodeResult, w EventWaiter) {
+			defer wg.Done()
+
+			w.Timeout = -p.Since(deadline)
+			nr.Notification, nr.Err = p.WaitForEvent(from, w)
+			nr.Duration = p.Since(start)
+			nr.Status = waitStatus(nr.Err)
+		}(&r[i], nw)
+	}
+	wg.Wait()
+	return
+}
+
+// waitStatus tells how a wait that returned err ended.
+func waitStatus(err error) string {
+	var (
+		failure       *EventFailure
+		statusFailure *StatusFailure
+	)
+
+	switch {
+	case err == nil:
+		return NODE_WAIT_SUCCESS
+	case errors.As(err, &failure), errors.As(err, &statusFailure):
+		return NODE_WAIT_FAILURE
+	case errors.Is(err, ErrWaitTimeout):
+		return NODE_WAIT_TIMEOUT
+	}
+	return NODE_WAIT_ERROR
+}
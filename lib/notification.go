@@ -7,31 +7,214 @@ package pcc
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/platinasystems/tiles/pccserver/models"
 )
 
+const DEFAULT_NOTIFICATION_LIMIT = 50
+
 type Notification struct {
 	models.Notification
 }
 
+// NotificationFilter selects notifications from the history, newest
+// first.  Since, BeforeId, TargetId and Type are passed on to PCC, all
+// but Type are also applied here for the releases that ignore them.
+// BeforeId, if not 0, only selects the notifications older than that
+// id.
+type NotificationFilter struct {
+	Page     int
+	Limit    int
+	Since    time.Time
+	BeforeId uint64
+	TargetId uint64
+	Type     string
+}
+
+func (f NotificationFilter) query() string {
+	q := url.Values{}
+	q.Set("page", fmt.Sprint(f.Page))
+	if f.Limit <= 0 {
+		f.Limit = DEFAULT_NOTIFICATION_LIMIT
+	}
+	q.Set("limit", fmt.Sprint(f.Limit))
+	if !f.Since.IsZero() {
+		q.Set("from", fmt.Sprint(ConvertToMillis(f.Since)))
+	}
+	if f.BeforeId != 0 {
+		q.Set("beforeId", fmt.Sprint(f.BeforeId))
+	}
+	if f.TargetId != 0 {
+		q.Set("targetId", fmt.Sprint(f.TargetId))
+	}
+	if f.Type != "" {
+		q.Set("type", f.Type)
+	}
+	return q.Encode()
+}
+
+func (f NotificationFilter) match(n *Notification) bool {
+	if !f.Since.IsZero() && n.CreatedAt < ConvertToMillis(f.Since) {
+		return false
+	}
+	if f.BeforeId != 0 && n.Id >= f.BeforeId {
+		return false
+	}
+	if f.TargetId != 0 && n.TargetId != f.TargetId {
+		return false
+	}
+	return true
+}
+
+// GetNotifications returns the latest page of the history.
 func (p *PccClient) GetNotifications() (notifications []Notification, err error) {
+	return p.GetNotificationsPage(NotificationFilter{})
+}
+
+// GetNotificationsPage returns the page of the history selected by f.
+func (p *PccClient) GetNotificationsPage(f NotificationFilter) (
+	notifications []Notification, err error) {
+
 	var (
-		resp     HttpResp
-		endpoint string
+		resp HttpResp
+		page []Notification
 	)
 
-	page := 0
-	limit := 50
-	endpoint = fmt.Sprintf("pccserver/notifications/history"+
-		"?page=%vlimit=%v", page, limit)
+	endpoint := "pccserver/notifications/history?" + f.query()
 	resp, _, err = p.pccGateway("GET", endpoint, nil)
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(resp.Data, &notifications)
-	if err != nil {
+	if err = json.Unmarshal(resp.Data, &page); err != nil {
 		return
 	}
+	for i := range page {
+		if f.match(&page[i]) {
+			notifications = append(notifications, page[i])
+		}
+	}
+	return
+}
+
+// NotificationIterator walks the history page by page, newest first,
+// until it reaches notifications older than the Since of its filter or
+// the end of the history.  Each page is that before the oldest id seen,
+// so that the notifications raised meanwhile don't shift the pages.
+// From a PCC that ignores the cursor, or doesn't number its
+// notifications, the pages are fetched by offset, skipping those seen.
+//
+//	it := p.IterNotifications(pcc.NotificationFilter{Since: start})
+//	for it.Next() {
+//		n := it.Notification()
+//		...
+//	}
+//	err := it.Err()
+type NotificationIterator struct {
+	p      *PccClient
+	filter NotificationFilter
+	page   []Notification
+	next   int
+	cur    Notification
+	last   bool
+	err    error
+	// id of the oldest notification seen
+	cursor uint64
+	// pages fetched by offset
+	offset bool
+}
+
+// IterNotifications walks the history selected by f from the newest
+// notification, whatever its Page and BeforeId.
+func (p *PccClient) IterNotifications(f NotificationFilter) *NotificationIterator {
+	if f.Limit <= 0 {
+		f.Limit = DEFAULT_NOTIFICATION_LIMIT
+	}
+	f.Page, f.BeforeId = 0, 0
+	return &NotificationIterator{p: p, filter: f}
+}
+
+func (it *NotificationIterator) Next() bool {
+	for it.err == nil {
+		if it.next < len(it.page) {
+			it.cur = it.page[it.next]
+			it.next++
+			if !it.filter.Since.IsZero() &&
+				it.cur.CreatedAt < ConvertToMillis(it.filter.Since) {
+				it.last = true
+				it.page = nil
+				return false
+			}
+			if it.filter.match(&it.cur) {
+				return true
+			}
+			continue
+		}
+		if it.last {
+			return false
+		}
+		it.fetch()
+	}
+	return false
+}
+
+// fetch loads the next page.  A short page is the last one.
+func (it *NotificationIterator) fetch() {
+	var (
+		resp HttpResp
+		page []Notification
+	)
+
+	it.filter.BeforeId = it.cursor
+	endpoint := "pccserver/notifications/history?" + it.filter.query()
+	if resp, _, it.err = it.p.pccGateway("GET", endpoint,
+		nil); it.err != nil {
+		return
+	}
+	if it.err = json.Unmarshal(resp.Data, &page); it.err != nil {
+		return
+	}
+	it.page, it.next = nil, 0
+	if it.cursor != 0 && !it.offset && len(page) > 0 &&
+		page[0].Id >= it.cursor {
+		// the cursor was ignored and the newest page sent again
+		it.offset = true
+		it.filter.Page = 1
+		return
+	}
+	it.page = page
+	it.last = len(page) < it.filter.Limit
+	for i := range page {
+		if id := page[i].Id; it.cursor == 0 || id < it.cursor {
+			it.cursor = id
+		}
+	}
+	if it.cursor == 0 {
+		it.offset = true
+	}
+	if it.offset {
+		it.filter.Page++
+	}
+}
+
+func (it *NotificationIterator) Notification() Notification {
+	return it.cur
+}
+
+func (it *NotificationIterator) Err() error {
+	return it.err
+}
+
+// GetNotificationsSince returns all the notifications raised since from,
+// newest first, however many pages they take.
+func (p *PccClient) GetNotificationsSince(from time.Time) (
+	notifications []Notification, err error) {
+
+	it := p.IterNotifications(NotificationFilter{Since: from})
+	for it.Next() {
+		notifications = append(notifications, it.Notification())
+	}
+	err = it.Err()
 	return
 }
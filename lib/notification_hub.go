@@ -0,0 +1,431 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NOTIFICATION_STREAM_ENDPOINT = "pccserver/notifications/stream"
+
+	HUB_STREAM = "stream"
+	HUB_POLL   = "poll"
+
+	// the stream is opened again one period after it broke, then
+	// twice as late after every failure, up to that many periods
+	HUB_MAX_BACKOFF_PERIODS = 32
+)
+
+// ErrHubClosed is returned to the subscribers of a hub that was closed.
+var ErrHubClosed = errors.New("notification hub closed")
+
+// errNoStream is returned when the transport can't carry a stream, e.g.
+// a cassette that reads whole replies.
+var errNoStream = errors.New("transport doesn't stream")
+
+// NotificationHub receives the notifications of PCC once and passes
+// them on to all its subscribers.  It follows the server-sent events
+// stream of PCC and, when PCC has none or the stream breaks, polls the
+// history every period instead, trying to open the stream again with a
+// growing backoff.  While a hub is started WaitForEvent waits on it
+// rather than polling on its own.
+type NotificationHub struct {
+	p      *PccClient
+	period time.Duration
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu   sync.Mutex
+	subs map[*Subscription]bool
+	mode string
+	// CreatedAt of the newest notification passed on and the keys of
+	// those raised in that same millisecond
+	last   uint64
+	seen   map[string]bool
+	closed bool
+}
+
+// Subscription queues the notifications passed on by a hub, oldest
+// first, until it is closed.
+type Subscription struct {
+	hub    *NotificationHub
+	mu     sync.Mutex
+	queue  []Notification
+	ready  chan struct{}
+	closed bool
+}
+
+func notificationKey(n *Notification) string {
+	return fmt.Sprint(n.CreatedAt, "/", n.TargetId, "/", n.Message)
+}
+
+// StartNotificationHub starts the hub of p, shared with its copies, or
+// returns the one already started.  period is the polling period,
+// FREQUENCY seconds by default.  The hub of a client replaying a
+// cassette follows nothing and isn't used by the waits.
+func (p *PccClient) StartNotificationHub(period time.Duration) (
+	h *NotificationHub) {
+
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	if p.session.hub != nil {
+		return p.session.hub
+	}
+	if period == 0 {
+		period = FREQUENCY * time.Second
+	}
+	ctx, cancel := context.WithCancel(p.Context())
+	h = &NotificationHub{
+		p:      p,
+		period: period,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		subs:   make(map[*Subscription]bool),
+		last:   ConvertToMillis(p.Clock().Now()),
+		seen:   make(map[string]bool),
+	}
+	if p.replaying() {
+		// nothing to follow: the waits read the notifications of the
+		// cassette from its history
+		close(h.done)
+		return
+	}
+	p.session.hub = h
+	go h.run(ctx)
+	return
+}
+
+// NotificationHub returns the hub started for p, if any.
+func (p *PccClient) NotificationHub() *NotificationHub {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	return p.session.hub
+}
+
+// Close stops the hub and its subscriptions.
+func (h *NotificationHub) Close() {
+	h.p.session.mu.Lock()
+	if h.p.session.hub == h {
+		h.p.session.hub = nil
+	}
+	h.p.session.mu.Unlock()
+
+	h.cancel()
+	<-h.done
+	h.mu.Lock()
+	h.closed = true
+	subs := h.subs
+	h.subs = make(map[*Subscription]bool)
+	h.mu.Unlock()
+	for sub := range subs {
+		sub.close()
+	}
+}
+
+// Mode tells whether the hub follows the stream of PCC or polls.
+func (h *NotificationHub) Mode() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.mode
+}
+
+// Subscribers returns how many subscriptions are open.
+func (h *NotificationHub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Subscribe returns a subscription to the notifications passed on from
+// now on.
+func (h *NotificationHub) Subscribe() *Subscription {
+	sub := &Subscription{hub: h, ready: make(chan struct{}, 1)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.closed = true
+	} else {
+		h.subs[sub] = true
+	}
+	return sub
+}
+
+func (h *NotificationHub) setMode(mode string) {
+	h.mu.Lock()
+	h.mode = mode
+	h.mu.Unlock()
+}
+
+func (h *NotificationHub) run(ctx context.Context) {
+	defer close(h.done)
+
+	h.setMode(HUB_STREAM)
+	backoff := h.period
+	for {
+		connected, err := h.stream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errNoStream) {
+			h.p.logf(LOG_INFO, nil, "notification stream: %v, "+
+				"polling every %v", err, h.period)
+			h.setMode(HUB_POLL)
+			h.poll(ctx, 0)
+			return
+		}
+		level := LOG_DEBUG
+		if connected || h.Mode() == HUB_STREAM {
+			level = LOG_INFO
+			backoff = h.period
+		}
+		h.p.logf(level, nil, "notification stream: %v, polling every "+
+			"%v, opening it again in %v", err, h.period, backoff)
+		h.setMode(HUB_POLL)
+		if !h.poll(ctx, backoff) {
+			return
+		}
+		if backoff *= 2; backoff > HUB_MAX_BACKOFF_PERIODS*h.period {
+			backoff = HUB_MAX_BACKOFF_PERIODS * h.period
+		}
+	}
+}
+
+// dispatch passes n on unless it was already.
+func (h *NotificationHub) dispatch(n Notification) {
+	key := notificationKey(&n)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case n.CreatedAt < h.last:
+		return
+	case n.CreatedAt > h.last:
+		h.last = n.CreatedAt
+		h.seen = make(map[string]bool)
+	case h.seen[key]:
+		return
+	}
+	h.seen[key] = true
+	for sub := range h.subs {
+		sub.push(n)
+	}
+}
+
+// stream follows the event stream of PCC until it breaks, telling
+// whether it could be opened.  Each event carries one notification as
+// JSON in its data.
+func (h *NotificationHub) stream(ctx context.Context) (connected bool,
+	err error) {
+
+	var r *http.Response
+
+	if r, err = h.openStream(ctx); err != nil {
+		return
+	}
+	defer r.Body.Close()
+	connected = true
+	if h.Mode() == HUB_POLL {
+		h.p.logf(LOG_INFO, nil, "notification stream opened again")
+	}
+	h.setMode(HUB_STREAM)
+	// what was raised while the stream was being opened
+	if err = h.catchUp(h.p.WithContext(ctx)); err != nil {
+		return
+	}
+
+	var data []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) == 0 {
+				continue
+			}
+			var n Notification
+			if err = json.Unmarshal([]byte(strings.Join(data, "\n")),
+				&n); err != nil {
+				return
+			}
+			data = nil
+			h.dispatch(n)
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(
+				strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err = scanner.Err(); err == nil {
+		err = fmt.Errorf("closed by PCC")
+	}
+	return
+}
+
+func (h *NotificationHub) openStream(ctx context.Context) (
+	r *http.Response, err error) {
+
+	p := h.p
+	switch p.client.Transport.(type) {
+	case *recorder, *player:
+		err = errNoStream
+		return
+	}
+	// the response timeout of p would cut the stream
+	client := *p.client
+	client.Timeout = 0
+
+	for attempt := 0; attempt < 2; attempt++ {
+		var req *http.Request
+
+		bearer := p.bearer()
+		req, err = http.NewRequestWithContext(ctx, "GET",
+			p.URL(NOTIFICATION_STREAM_ENDPOINT), nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Authorization", bearer)
+		if r, err = client.Do(req); err != nil {
+			return
+		}
+		if r.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			r.Body.Close()
+			if err = p.refresh(bearer); err != nil {
+				return
+			}
+			continue
+		}
+		break
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.StatusCode != http.StatusOK || contentType != "text/event-stream" {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if r.StatusCode == http.StatusOK {
+			err = fmt.Errorf("%v is not an event stream", contentType)
+		} else {
+			err = newAPIError("GET", NOTIFICATION_STREAM_ENDPOINT, r,
+				body)
+		}
+		r = nil
+	}
+	return
+}
+
+// poll fetches the notifications raised since the newest one passed on,
+// once every period, for d or, if 0, until ctx is done.  It tells
+// whether it polled for d.
+func (h *NotificationHub) poll(ctx context.Context, d time.Duration) bool {
+	p := h.p.WithContext(ctx)
+	end := p.Clock().Now().Add(d)
+	for {
+		if err := h.catchUp(p); err != nil && ctx.Err() == nil {
+			p.logf(LOG_WARN, nil, "polling notifications: %v", err)
+		}
+		wait := h.period
+		if d > 0 {
+			left := -p.Since(end)
+			if left <= 0 {
+				return true
+			}
+			if left < wait {
+				wait = left
+			}
+		}
+		if p.Sleep(wait) != nil {
+			return false
+		}
+	}
+}
+
+// catchUp passes on the notifications raised since the newest one
+// passed on, oldest first.
+func (h *NotificationHub) catchUp(p *PccClient) (err error) {
+	var events []Notification
+
+	h.mu.Lock()
+	since := ConvertFromMillis(h.last)
+	h.mu.Unlock()
+	it := p.IterNotifications(NotificationFilter{Since: since})
+	for it.Next() {
+		events = append(events, it.Notification())
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		h.dispatch(events[i])
+	}
+	return it.Err()
+}
+
+func (sub *Subscription) push(n Notification) {
+	sub.mu.Lock()
+	sub.queue = append(sub.queue, n)
+	sub.mu.Unlock()
+	select {
+	case sub.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (sub *Subscription) close() {
+	sub.mu.Lock()
+	sub.closed = true
+	sub.mu.Unlock()
+	select {
+	case sub.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Next returns the oldest notification queued, waiting for one until
+// expired fires.  It fails with ErrWaitTimeout, ErrHubClosed or the
+// error of ctx.
+func (sub *Subscription) Next(ctx context.Context,
+	expired <-chan time.Time) (n Notification, err error) {
+
+	for {
+		sub.mu.Lock()
+		if len(sub.queue) > 0 {
+			n = sub.queue[0]
+			sub.queue = sub.queue[1:]
+			sub.mu.Unlock()
+			return
+		}
+		closed := sub.closed
+		sub.mu.Unlock()
+		if closed {
+			err = ErrHubClosed
+			return
+		}
+
+		select {
+		case <-sub.ready:
+		case <-expired:
+			err = ErrWaitTimeout
+			return
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
+
+// Close stops the subscription.
+func (sub *Subscription) Close() {
+	h := sub.hub
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+	sub.close()
+}
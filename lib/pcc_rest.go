@@ -28,28 +28,115 @@ type HttpResp struct {
 	Data    []byte
 }
 
+// doRequest sends op to endPoint over the shared transport and returns
+// the response with its body fully read.  err is only set when no
+// response was received, the HTTP status is left to the caller.
+// Transient failures are retried as the RetryConfig of p says.  The
+// request is counted in the metrics of p, if any.
+func (p *PccClient) doRequest(op string, endPoint string, contentType string,
+	data []byte) (r *http.Response, body []byte, err error) {
+
+	start := p.Clock().Now()
+	r, body, err = p.withRetry(op, endPoint, func() (*http.Response,
+		[]byte, error) {
+
+		return p.doAuthenticated(op, endPoint, contentType, data)
+	})
+	p.observeRequest(op, endPoint, r, err, p.Since(start))
+	return
+}
+
+// doAuthenticated sends the request with the current token.  A request
+// rejected for an expired token is sent again, once, after
+// authenticating anew.
+func (p *PccClient) doAuthenticated(op string, endPoint string,
+	contentType string, data []byte) (r *http.Response, body []byte,
+	err error) {
+
+	bearer := p.bearer()
+	r, body, err = p.send(op, endPoint, contentType, data, bearer)
+	if err != nil || !tokenExpired(r, body) {
+		return
+	}
+	if err = p.refresh(bearer); err != nil {
+		err = fmt.Errorf("%v %v: re-authentication failed: %v", op,
+			endPoint, err)
+		return
+	}
+	return p.send(op, endPoint, contentType, data, p.bearer())
+}
+
+func (p *PccClient) send(op string, endPoint string, contentType string,
+	data []byte, bearer string) (r *http.Response, body []byte, err error) {
+
+	req, err := http.NewRequestWithContext(p.Context(), op, p.URL(endPoint),
+		bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	if bearer != "" {
+		req.Header.Add("Authorization", bearer)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r, err = p.client.Do(req); err != nil {
+		err = fmt.Errorf("%v %v: %w", op, endPoint, err)
+		return
+	}
+	defer r.Body.Close()
+	if body, err = ioutil.ReadAll(r.Body); err != nil {
+		err = fmt.Errorf("%v %v: reading response: %v", op, endPoint,
+			err)
+	}
+	return
+}
+
+// tokenExpired tells whether PCC refused the request for its token,
+// either with the HTTP status or with the status of the gateway reply.
+func tokenExpired(r *http.Response, body []byte) bool {
+	if r.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+
+	var rg respGeneric
+	if json.Unmarshal(body, &rg) != nil {
+		return false
+	}
+	return rg.Status == http.StatusUnauthorized
+}
+
+// pccGateway sends a request to a PCC service behind the gateway and
+// unwraps its reply.  A reply with a failure status is returned along
+// with an *APIError.
 func (p *PccClient) pccGateway(op string, endPoint string, data []byte) (
 	resp HttpResp, body []byte, err error) {
 
-	client := &http.Client{}
-	url := fmt.Sprintf("https://%s:9999/%v", p.pccIp, endPoint)
-	req, _ := http.NewRequest(op, url, bytes.NewBuffer(data))
-	req.Header.Add("Authorization", p.bearer)
-	r, _ := client.Do(req)
-	defer r.Body.Close()
-	body, _ = ioutil.ReadAll(r.Body)
+	var r *http.Response
+
+	if r, body, err = p.doRequest(op, endPoint, "",
+		data); err != nil {
+		return
+	}
 
 	var (
 		rg       respGeneric
 		dataJson []byte
 	)
 	if err = json.Unmarshal(body, &rg); err != nil {
-		fmt.Printf("Unmarshalling Error:\n%v\n", string(body))
+		if r.StatusCode != 200 {
+			err = newAPIError(op, endPoint, r, body)
+			resp = HttpResp{Status: r.StatusCode, Data: body}
+			return
+		}
+		p.logf(LOG_ERROR, Fields{"endpoint": endPoint},
+			"Unmarshalling Error:\n%v", string(body))
 		return
 	}
 
 	if dataJson, err = json.Marshal(rg.Data); err != nil {
-		fmt.Printf("Marshalling Error:\n%v\n", rg.Data)
+		p.logf(LOG_ERROR, Fields{"endpoint": endPoint},
+			"Marshalling Error:\n%v", rg.Data)
 		return
 	}
 	resp = HttpResp{
@@ -59,45 +146,42 @@ func (p *PccClient) pccGateway(op string, endPoint string, data []byte) (
 		Error:   rg.Error,
 		Data:    dataJson,
 	}
+	if r.StatusCode != 200 || rg.Status != 200 {
+		err = newAPIError(op, endPoint, r, body)
+	}
 	return
 }
 
+// pccSecurity returns the raw reply of the security services, with an
+// *APIError if it reports a failure.
 func (p *PccClient) pccSecurity(op string, endPoint string, data []byte) (resp HttpResp, body []byte, err error) {
-	client := &http.Client{}
-	url := fmt.Sprintf("https://%s:9999/%v", p.pccIp, endPoint)
-	req, _ := http.NewRequest(op, url, bytes.NewBuffer(data))
-	req.Header.Add("Authorization", p.bearer)
-	r, _ := client.Do(req)
-	defer r.Body.Close()
-	body, _ = ioutil.ReadAll(r.Body)
+	var r *http.Response
 
+	if r, body, err = p.doRequest(op, endPoint, "",
+		data); err != nil {
+		return
+	}
 	resp = HttpResp{
 		Status: r.StatusCode,
 		Data:   body,
 	}
+	if r.StatusCode != 200 {
+		err = newAPIError(op, endPoint, r, body)
+	}
 	return
 }
 
 func (p *PccClient) pccUserManagement(op string, endPoint string, data []byte) (
 	body []byte, err error) {
 
-	client := &http.Client{}
-	url := fmt.Sprintf("https://%s:9999/%v", p.pccIp, endPoint)
-	req, _ := http.NewRequest(op, url, bytes.NewBuffer(data))
-	req.Header.Add("Authorization", p.bearer)
-	req.Header.Add("Content-type", "application/json")
-	r, _ := client.Do(req)
-	defer r.Body.Close()
-	body, _ = ioutil.ReadAll(r.Body)
-	if r.StatusCode == 200 {
-		return
-	}
+	var r *http.Response
 
-	var rg respGeneric
-	if err = json.Unmarshal(body, &rg); err != nil {
-		fmt.Printf("Unmarshalling Error:\n%v\n", string(body))
+	if r, body, err = p.doRequest(op, endPoint, "application/json",
+		data); err != nil {
 		return
 	}
-	err = fmt.Errorf("%v: %v", r.Status, rg.Error)
+	if r.StatusCode != 200 {
+		err = newAPIError(op, endPoint, r, body)
+	}
 	return
 }
@@ -0,0 +1,90 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+// installMaas adds host and installs MaaS on it, as TestMaaS does.
+func installMaas(p *pcc.PccClient, host string) (id uint64, err error) {
+	node, err := p.AddNode(host, true)
+	if err != nil {
+		return
+	}
+	id = node.Id
+
+	from := p.Clock().Now()
+	node.RoleIds = []uint64{2, 6}
+	if _, err = p.UpdateNode(node); err != nil {
+		return
+	}
+	seq, err := p.Catalog().MaasInstallSequence(id)
+	if err != nil {
+		return
+	}
+	seq.Period = 20 * time.Millisecond
+	seq.Timeout = 5 * time.Second
+	_, err = p.WaitForSequence(from, seq)
+	return
+}
+
+func TestCassetteReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "maas.jsonl")
+
+	s, p := newClient(t, pcc.PccClientConfig{
+		Cassette: pcc.CassetteConfig{
+			Mode: pcc.CASSETTE_RECORD,
+			File: file,
+		},
+	})
+	s.Delay = 20 * time.Millisecond
+	s.AddInterface("10.0.0.1", "eth0", "aa:bb:cc:dd:ee:01", true)
+	recorded, err := installMaas(p, "10.0.0.1")
+	s.Close()
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the notifications recorded are all older than the replay
+	time.Sleep(100 * time.Millisecond)
+	p, err = pcc.AuthenticateURL(s.URL, pcc.Credential{}, pcc.PccClientConfig{
+		Cassette: pcc.CassetteConfig{
+			Mode: pcc.CASSETTE_REPLAY,
+			File: file,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as TestMain does; the waits read the history of the cassette
+	// instead
+	hub := p.StartNotificationHub(0)
+	defer hub.Close()
+	if p.NotificationHub() != nil {
+		t.Error("hub following a replay")
+	}
+	replayed, err := installMaas(p, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed != recorded {
+		t.Errorf("replay added node %v, recorded %v", replayed, recorded)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+	"github.com/platinasystems/tiles/pccserver/models"
+)
+
+type fakeCephCluster struct {
+	models.CephCluster
+}
+
+type fakeCephPool struct {
+	models.CephPool
+	cluster *fakeCephCluster
+}
+
+type fakeCephFS struct {
+	models.CephFS
+	cluster *fakeCephCluster
+}
+
+func (s *Server) cephRoutes() {
+	s.handle("GET", "pccserver/storage/ceph/cluster", s.getCephClusters)
+	s.handle("POST", "pccserver/storage/ceph/cluster", s.createCephCluster)
+	s.handle("DELETE", "pccserver/storage/ceph/cluster/([0-9]+)",
+		s.deleteCephCluster)
+	s.handle("GET", "pccserver/storage/ceph/cluster/([0-9]+)/pools",
+		s.getCephPools)
+	s.handle("GET", "pccserver/storage/ceph/cluster/([0-9]+)/fs",
+		s.getCephFS)
+	s.handle("POST", "pccserver/storage/ceph/pool", s.createCephPool)
+	s.handle("DELETE", "pccserver/storage/ceph/pool/([0-9]+)",
+		s.deleteCephPool)
+	s.handle("POST", "pccserver/storage/ceph/fs", s.createCephFS)
+	s.handle("DELETE", "pccserver/storage/ceph/fs/([0-9]+)",
+		s.deleteCephFS)
+}
+
+func (s *Server) cephCluster(w http.ResponseWriter, id uint64) (
+	c *fakeCephCluster) {
+
+	if c = s.cephClusters[id]; c == nil {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("ceph cluster %v not found", id))
+	}
+	return
+}
+
+func parseId(arg string) (id uint64) {
+	id, _ = strconv.ParseUint(arg, 10, 64)
+	return
+}
+
+func (s *Server) getCephClusters(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	clusters := []*models.CephCluster{}
+	for _, c := range s.cephClusters {
+		clusters = append(clusters, &c.CephCluster)
+	}
+	s.reply(w, r, clusters)
+}
+
+func (s *Server) createCephCluster(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.CreateCephClusterRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	for _, c := range s.cephClusters {
+		if c.Name == req.Name {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("ceph cluster %v already exists",
+					req.Name))
+			return
+		}
+	}
+	for _, n := range req.Nodes {
+		if _, found := s.nodes[n.ID]; !found {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("node %v not found", n.ID))
+			return
+		}
+	}
+
+	c := &fakeCephCluster{}
+	c.Id = s.nextId()
+	c.Name = req.Name
+	s.cephClusters[c.Id] = c
+
+	var steps []func()
+	for _, msg := range []string{
+		pcc.CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_1,
+		pcc.CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_7,
+		pcc.CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_2,
+		pcc.CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_6,
+		pcc.CEPH_INSTALLATION_INTERMEDIATE_NOTIFICATION_8,
+		pcc.CEPH_INSTALLATION_SUCCESS_NOTIFICATION,
+	} {
+		msg := msg
+		steps = append(steps, func() {
+			s.notify(c.Id, LEVEL_INFO, msg)
+		})
+	}
+	s.sequence(steps...)
+	s.reply(w, r, c.CephCluster)
+}
+
+func (s *Server) deleteCephCluster(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	c := s.cephCluster(w, parseId(args[0]))
+	if c == nil {
+		return
+	}
+	s.sequence(func() {
+		s.notify(c.Id, LEVEL_INFO,
+			pcc.CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_1)
+	}, func() {
+		s.notify(c.Id, LEVEL_INFO,
+			pcc.CEPH_UNINSTALLATION_INTERMEDIATE_NOTIFICATION_2)
+	}, func() {
+		for id, p := range s.cephPools {
+			if p.cluster == c {
+				delete(s.cephPools, id)
+			}
+		}
+		for id, fs := range s.cephFS {
+			if fs.cluster == c {
+				delete(s.cephFS, id)
+			}
+		}
+		delete(s.cephClusters, c.Id)
+		s.notify(c.Id, LEVEL_INFO,
+			pcc.CEPH_UNINSTALLATION_SUCCESS_NOTIFICATION)
+	})
+	s.reply(w, r, c.CephCluster)
+}
+
+func (s *Server) getCephPools(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	c := s.cephCluster(w, parseId(args[0]))
+	if c == nil {
+		return
+	}
+	pools := []*models.CephPool{}
+	for _, p := range s.cephPools {
+		if p.cluster == c {
+			pools = append(pools, &p.CephPool)
+		}
+	}
+	s.reply(w, r, pools)
+}
+
+func (s *Server) createCephPool(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.CreateCephPoolRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	c := s.cephCluster(w, req.CephClusterId)
+	if c == nil {
+		return
+	}
+
+	p := &fakeCephPool{cluster: c}
+	p.Id = s.nextId()
+	p.Name = req.Name
+	s.cephPools[p.Id] = p
+	s.sequence(func() {
+		s.notify(c.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_POOL_CREATION_INTERMEDIATE_NOTIFICATION_1,
+			p.Name, c.Name))
+	}, func() {
+		s.notify(c.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_POOL_CREATION_SUCCESS_NOTIFICATION,
+			p.Name, c.Name))
+	})
+	s.reply(w, r, p.CephPool)
+}
+
+func (s *Server) deleteCephPool(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	p, found := s.cephPools[parseId(args[0])]
+	if !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("ceph pool %v not found", args[0]))
+		return
+	}
+	s.sequence(func() {
+		s.notify(p.cluster.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_POOL_DELETION_INTERMEDIATE_NOTIFICATION_1,
+			p.Name, p.cluster.Name))
+	}, func() {
+		delete(s.cephPools, p.Id)
+		s.notify(p.cluster.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_POOL_DELETION_SUCCESS_NOTIFICATION, p.Name))
+	})
+	s.reply(w, r, p.CephPool)
+}
+
+func (s *Server) getCephFS(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	c := s.cephCluster(w, parseId(args[0]))
+	if c == nil {
+		return
+	}
+	fsList := []*models.CephFS{}
+	for _, fs := range s.cephFS {
+		if fs.cluster == c {
+			fsList = append(fsList, &fs.CephFS)
+		}
+	}
+	s.reply(w, r, fsList)
+}
+
+func (s *Server) createCephFS(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.CreateCephFSRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	c := s.cephCluster(w, req.CephClusterId)
+	if c == nil {
+		return
+	}
+
+	fs := &fakeCephFS{cluster: c}
+	fs.Id = s.nextId()
+	fs.Name = req.Name
+	s.cephFS[fs.Id] = fs
+	s.sequence(func() {
+		s.notify(c.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_FS_CREATION_INTERMEDIATE_NOTIFICATION_1,
+			fs.Name, c.Name))
+	}, func() {
+		s.notify(c.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_FS_CREATION_SUCCESS_NOTIFICATION,
+			fs.Name, c.Name))
+	})
+	s.reply(w, r, fs.CephFS)
+}
+
+func (s *Server) deleteCephFS(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	fs, found := s.cephFS[parseId(args[0])]
+	if !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("ceph fs %v not found", args[0]))
+		return
+	}
+	s.sequence(func() {
+		s.notify(fs.cluster.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_FS_DELETION_INTERMEDIATE_NOTIFICATION_1,
+			fs.Name))
+	}, func() {
+		delete(s.cephFS, fs.Id)
+		s.notify(fs.cluster.Id, LEVEL_INFO, fmt.Sprintf(
+			pcc.CEPH_FS_DELETION_SUCCESS_NOTIFICATION, fs.Name))
+	})
+	s.reply(w, r, fs.CephFS)
+}
@@ -0,0 +1,156 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// the total cpu time of the host between two samples, in ns
+const DOCKER_SYSTEM_USAGE = 1000000000000
+
+// PCC_CONTAINERS are the containers of a PCC appliance.
+var PCC_CONTAINERS = []string{"pccserver", "gateway", "monitor",
+	"platina-executor", "key-manager", "postgres", "redis"}
+
+// Container is the usage a DockerAPI reports for a container.  CPU is in
+// percent of one CPU, Memory and MemoryLimit in bytes.
+type Container struct {
+	Name        string
+	CPU         float64
+	Memory      uint64
+	MemoryLimit uint64
+	PIDs        int
+}
+
+// DockerAPI stands in for the Docker Engine API of the PCC host, serving
+// the stats of its containers over tcp or a unix socket.
+type DockerAPI struct {
+	*httptest.Server
+
+	host string
+
+	mu         sync.Mutex
+	containers map[string]*Container
+}
+
+// NewDockerAPI starts a Docker Engine API on a port of 127.0.0.1.
+func NewDockerAPI() *DockerAPI {
+	d := newDockerAPI()
+	d.Start()
+	d.host = "tcp://" + d.Listener.Addr().String()
+	return d
+}
+
+// NewDockerAPIUnix starts a Docker Engine API on the unix socket at path.
+func NewDockerAPIUnix(path string) (d *DockerAPI, err error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return
+	}
+	d = newDockerAPI()
+	d.Listener.Close()
+	d.Listener = l
+	d.Start()
+	d.host = "unix://" + path
+	return
+}
+
+func newDockerAPI() *DockerAPI {
+	d := &DockerAPI{containers: make(map[string]*Container)}
+	d.Server = httptest.NewUnstartedServer(http.HandlerFunc(d.serve))
+	return d
+}
+
+// Host is the address of d, as in DockerStatsConfig.Host.
+func (d *DockerAPI) Host() string {
+	return d.host
+}
+
+// SetContainer adds c, or updates the container of that name.
+func (d *DockerAPI) SetContainer(c Container) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.containers[c.Name] = &c
+}
+
+// RemoveContainer removes the container name.
+func (d *DockerAPI) RemoveContainer(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.containers, name)
+}
+
+func (d *DockerAPI) serve(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method != "GET":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	case path == "/containers/json":
+		var names []string
+		for name := range d.containers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		list := []map[string]interface{}{}
+		for _, name := range names {
+			list = append(list, map[string]interface{}{
+				"Id":    containerId(name),
+				"Names": []string{"/" + name},
+			})
+		}
+		json.NewEncoder(w).Encode(list)
+	case strings.HasPrefix(path, "/containers/") &&
+		strings.HasSuffix(path, "/stats"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path,
+			"/containers/"), "/stats")
+		for _, c := range d.containers {
+			if containerId(c.Name) == id {
+				json.NewEncoder(w).Encode(containerStats(c))
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf(`{"message":"No such container: %v"}`,
+			id), http.StatusNotFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func containerId(name string) string {
+	return fmt.Sprintf("%x", name)
+}
+
+// containerStats is the reply of the Engine API for a sample of c, on a
+// host with one CPU.
+func containerStats(c *Container) map[string]interface{} {
+	cpu := func(total uint64, system uint64) map[string]interface{} {
+		return map[string]interface{}{
+			"cpu_usage":        map[string]interface{}{"total_usage": total},
+			"system_cpu_usage": system,
+			"online_cpus":      1,
+		}
+	}
+	return map[string]interface{}{
+		"cpu_stats": cpu(uint64(c.CPU/100*DOCKER_SYSTEM_USAGE),
+			DOCKER_SYSTEM_USAGE),
+		"precpu_stats": cpu(0, 0),
+		"memory_stats": map[string]interface{}{
+			"usage": c.Memory,
+			"limit": c.MemoryLimit,
+		},
+		"pids_stats": map[string]interface{}{"current": c.PIDs},
+	}
+}
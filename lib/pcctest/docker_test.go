@@ -0,0 +1,87 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+// newDockerStats samples d into files of the directory dir.
+func newDockerStats(t *testing.T, d *DockerAPI) (ds *pcc.DockerStats,
+	dir string) {
+
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds = pcc.InitDockerStats(pcc.DockerStatsConfig{
+		OutputFile: filepath.Join(dir, "container-stats.txt"),
+		CSVFile:    filepath.Join(dir, "container-stats.csv"),
+		JSONFile:   filepath.Join(dir, "container-stats.jsonl"),
+		Period:     3600,
+		Source:     pcc.DOCKER_SOURCE_API,
+		Host:       d.Host(),
+	})
+	return
+}
+
+func TestDockerSampleBytes(t *testing.T) {
+	d := NewDockerAPI()
+	defer d.Close()
+	d.SetContainer(Container{
+		Name:        "pccserver",
+		CPU:         12.5,
+		Memory:      1234567891,
+		MemoryLimit: 8589934593,
+		PIDs:        42,
+	})
+	ds, dir := newDockerStats(t, d)
+	defer os.RemoveAll(dir)
+
+	samples, err := ds.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("%v samples, want 1", len(samples))
+	}
+	s := samples[0]
+	// the bytes as the API counts them, not as docker stats prints them
+	if s.Memory != 1234567891 || s.MemoryLimit != 8589934593 {
+		t.Errorf("memory %v / %v, want 1234567891 / 8589934593",
+			s.Memory, s.MemoryLimit)
+	}
+	if s.Container != "pccserver" || s.CPU != 12.5 || s.PIDs != 42 {
+		t.Errorf("%+v", s)
+	}
+
+	summary := ds.Summary()
+	if len(summary) != 1 || len(summary[0].Containers) != 1 ||
+		summary[0].Containers[0].MemoryMax != 1234567891 {
+		t.Errorf("summary %+v", summary)
+	}
+
+	ds.Stop()
+	text, err := ioutil.ReadFile(filepath.Join(dir, "container-stats.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "MEMORY=1.1GiB / 8.0GiB") {
+		t.Errorf("text output: %s", text)
+	}
+	csv, err := ioutil.ReadFile(filepath.Join(dir, "container-stats.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(csv), ",1234567891,8589934593,") {
+		t.Errorf("csv output: %s", csv)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+const (
+	K8S_DEPLOY_STATUS_DELETING = "deleting"
+	K8S_HEALTH_GOOD            = "good"
+)
+
+func (s *Server) kubernetesRoutes() {
+	s.handle("GET", "pccserver/kubernetes", s.getKubernetes)
+	s.handle("POST", "pccserver/kubernetes", s.createKubernetes)
+	s.handle("GET", "pccserver/kubernetes/([0-9]+)", s.getKubernetesId)
+	s.handle("DELETE", "pccserver/kubernetes/([0-9]+)",
+		s.deleteKubernetes)
+}
+
+func (s *Server) k8sCluster(w http.ResponseWriter, arg string) (
+	c *pcc.K8sCluster) {
+
+	id := parseId(arg)
+	if c = s.k8sClusters[id]; c == nil {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("cluster %v doesn't exist", id))
+	}
+	return
+}
+
+func (s *Server) getKubernetes(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	clusters := []*pcc.K8sCluster{}
+	for _, c := range s.k8sClusters {
+		clusters = append(clusters, c)
+	}
+	s.reply(w, r, clusters)
+}
+
+func (s *Server) getKubernetesId(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	if c := s.k8sCluster(w, args[0]); c != nil {
+		s.reply(w, r, c)
+	}
+}
+
+// createKubernetes deploys in four steps of 25%, then reports the
+// cluster healthy.
+func (s *Server) createKubernetes(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.K8sClusterRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	for _, c := range s.k8sClusters {
+		if c.Name == req.Name {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("cluster %v already exists",
+					req.Name))
+			return
+		}
+	}
+	for _, n := range req.Nodes {
+		if _, found := s.nodes[n.ID]; !found {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("node %v not found", n.ID))
+			return
+		}
+	}
+
+	c := &pcc.K8sCluster{}
+	c.ID = s.nextId()
+	c.Name = req.Name
+	c.DeployStatus = pcc.K8S_DEPLOY_STATUS_PROGRESS
+	s.k8sClusters[c.ID] = c
+
+	var steps []func()
+	for percent := int8(25); percent < 100; percent += 25 {
+		percent := percent
+		steps = append(steps, func() {
+			c.AnsibleJob.ProgressPercentage = percent
+		})
+	}
+	steps = append(steps, func() {
+		c.AnsibleJob.ProgressPercentage = 100
+		c.DeployStatus = pcc.K8S_DEPLOY_STATUS_COMPLETED
+		s.notify(c.ID, LEVEL_INFO, fmt.Sprintf(
+			"Kubernetes cluster %v has been installed", c.Name))
+	}, func() {
+		c.HealthStatus = K8S_HEALTH_GOOD
+	})
+	s.sequence(steps...)
+	s.reply(w, r, c)
+}
+
+func (s *Server) deleteKubernetes(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	c := s.k8sCluster(w, args[0])
+	if c == nil {
+		return
+	}
+	c.DeployStatus = K8S_DEPLOY_STATUS_DELETING
+	c.AnsibleJob.ProgressPercentage = 0
+	s.sequence(func() {
+		c.AnsibleJob.ProgressPercentage = 50
+	}, func() {
+		delete(s.k8sClusters, c.ID)
+		s.notify(c.ID, LEVEL_INFO, fmt.Sprintf(
+			"Kubernetes cluster %v has been deleted", c.Name))
+	})
+	s.reply(w, r, c)
+}
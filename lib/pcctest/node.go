@@ -0,0 +1,468 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+	"github.com/platinasystems/tiles/pccserver/models"
+)
+
+const (
+	ROLE_LLDP = 2
+	ROLE_MAAS = 6
+
+	APP_LLDP = "lldpd"
+	APP_MAAS = "maas"
+
+	STATUS_ADDING    = "Adding node..."
+	STATUS_ADD_FAIL  = "Add node failed"
+	STATUS_READY     = "Ready"
+	STATUS_UPDATING  = "Updating node..."
+	STATUS_DELETING  = "Deleting node..."
+	STATUS_REIMAGING = "Reimaging node..."
+)
+
+var maasNotifications = []string{
+	"[MAAS] Starting Bare-metal Role ",
+	"Bare Metal Dependencies in progress",
+	"Bare Metal Dependencies playbook completed",
+	"Bare Metal Image Repository in progress",
+	"Updated Platina Utility Linux source media",
+	"Bare Metal Image Repository playbook completed",
+	"[MAAS] Bare-metal deployment Role has been installed",
+	"Bare Metal Multitenancy in progress",
+	"Updating private deployment repository for tenant 'ROOT'",
+	"Bare Metal Multitenancy playbook completed",
+}
+
+type fakeNode struct {
+	pcc.NodeDetail
+	tenant  uint64
+	apps    map[string]bool
+	desired map[int64]pcc.InterfaceRequest
+	// when the node was added, in milliseconds, and the steps of its
+	// installation not started yet
+	added   uint64
+	install []func()
+}
+
+func (s *Server) nodeRoutes() {
+	s.handle("GET", "pccserver/node", s.getNodes)
+	s.handle("GET", "pccserver/node/([0-9]+)", s.getNode)
+	s.handle("GET", "pccserver/node/summary/([0-9]+)", s.getNode)
+	s.handle("GET", "pccserver/node/([0-9]+)/provisionStatus",
+		s.getProvisionStatus)
+	s.handle("GET", "pccserver/node/([0-9]+)/apps", s.getApps)
+	s.handle("POST", "pccserver/node/add", s.addNode)
+	s.handle("PUT", "pccserver/node/update", s.updateNode)
+	s.handle("DELETE", "pccserver/node/([0-9]+)", s.delNode)
+
+	s.handle("POST", "pccserver/interface", s.setIface)
+	s.handle("POST", "pccserver/interface/apply", s.applyIface)
+	s.handle("POST", "pccserver/interface/(up|down)", s.setIfaceAdmin)
+
+	s.handle("POST", "maas/deployments", s.maasDeploy)
+	s.handle("GET", "pccserver/storage/node/([0-9]+)", s.getStorageNode)
+	s.handle("GET", "pccserver/hardware-inventory",
+		s.getHardwareInventory)
+}
+
+// AddInterface declares an interface that the node at host will report
+// once it has been added.
+func (s *Server) AddInterface(host string, name string, mac string,
+	management bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	iface := &pcc.InterfaceDetail{Interface: &pcc.Interface{}}
+	iface.Interface.Id = int64(s.nextId())
+	iface.Interface.Name = name
+	iface.Interface.MacAddress = mac
+	iface.Interface.IsManagement = management
+	iface.Interface.ManagedByPcc = !management
+	iface.Interface.AdminStatus = pcc.INTERFACE_STATUS_UP
+	iface.Interface.CarrierStatus = pcc.INTERFACE_STATUS_UP
+	iface.Interface.IntfState = pcc.Ready
+
+	for _, n := range s.nodes {
+		if n.Host == host {
+			iface.Interface.NodeId = n.Id
+			n.Interfaces = append(n.Interfaces, iface)
+			return
+		}
+	}
+	s.pendingIfaces[host] = append(s.pendingIfaces[host], iface)
+}
+
+// FailAddNode makes the next add of the node at host fail.
+func (s *Server) FailAddNode(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failHosts[host] = true
+}
+
+func (s *Server) node(w http.ResponseWriter, arg string) (n *fakeNode) {
+	id := parseId(arg)
+	if n = s.nodes[id]; n == nil {
+		s.fail(w, http.StatusBadRequest, "no such node",
+			fmt.Sprintf("node %v not found", id))
+	}
+	return
+}
+
+func (s *Server) getNodes(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	nodes := []*pcc.NodeDetail{}
+	for _, n := range s.nodes {
+		nodes = append(nodes, &n.NodeDetail)
+	}
+	s.reply(w, r, nodes)
+}
+
+func (s *Server) getNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	if n := s.node(w, args[0]); n != nil {
+		s.reply(w, r, n.NodeDetail)
+	}
+}
+
+func (s *Server) getProvisionStatus(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	if n := s.node(w, args[0]); n != nil {
+		s.reply(w, r, n.ProvisionStatus)
+	}
+}
+
+func (s *Server) getApps(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	n := s.node(w, args[0])
+	if n == nil {
+		return
+	}
+	apps := []pcc.ProvisionedApp{}
+	for id, installed := range n.apps {
+		var app pcc.ProvisionedApp
+		app.ID = id
+		app.Local.Installed = installed
+		apps = append(apps, app)
+	}
+	s.reply(w, r, apps)
+}
+
+func (s *Server) addNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.NodeWithKubernetes
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	for _, n := range s.nodes {
+		if n.Host == req.Host {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("node %v already exists", req.Host))
+			return
+		}
+	}
+
+	n := &fakeNode{
+		tenant:  ROOT_TENANT_ID,
+		apps:    make(map[string]bool),
+		desired: make(map[int64]pcc.InterfaceRequest),
+	}
+	n.Id = s.nextId()
+	n.Host = req.Host
+	n.Name = "node-" + strings.Replace(req.Host, ".", "-", -1)
+	n.Managed = req.Managed
+	n.ProvisionStatus = STATUS_ADDING
+	n.Interfaces = s.pendingIfaces[req.Host]
+	for _, iface := range n.Interfaces {
+		iface.Interface.NodeId = n.Id
+	}
+	delete(s.pendingIfaces, req.Host)
+	s.nodes[n.Id] = n
+
+	if s.failHosts[req.Host] {
+		delete(s.failHosts, req.Host)
+		s.sequence(func() {
+			n.ProvisionStatus = STATUS_ADD_FAIL
+			s.notify(n.Id, LEVEL_ERROR,
+				fmt.Sprintf("add node at %v failed", n.Host))
+		})
+	} else {
+		// started by startInstall
+		n.added = pcc.ConvertToMillis(time.Now())
+		n.install = []func(){func() {
+			s.notify(n.Id, LEVEL_INFO,
+				"The agent has been installed")
+		}, func() {
+			s.notify(n.Id, LEVEL_INFO,
+				"The collector has been installed")
+		}, func() {
+			n.ProvisionStatus = STATUS_READY
+			n.NodeAvailabilityStatus = &models.NodeAvailability{
+				ConnectionStatus: "online",
+			}
+		}}
+	}
+	s.reply(w, r, n.NodeWithAdditionalFields)
+}
+
+// startInstall starts installing the agent and collector of the node
+// targetId, if added before from.  A real PCC takes minutes to install
+// them, longer than a client waits before asking for the notifications
+// of the node: holding them until then, rather than for a longer Delay,
+// keeps them after the time the client asks from without slowing it.
+func (s *Server) startInstall(targetId uint64, from uint64) {
+	n := s.nodes[targetId]
+	if n == nil || n.install == nil || from < n.added {
+		return
+	}
+	s.sequence(n.install...)
+	n.install = nil
+}
+
+func hasRole(roles []uint64, role uint64) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) installLLDP(n *fakeNode) {
+	s.sequence(func() {
+		n.apps[APP_LLDP] = true
+		s.notify(n.Id, LEVEL_INFO, "[LLDPD] Installed version 1.0.4")
+	})
+}
+
+func (s *Server) installMAAS(n *fakeNode) {
+	var steps []func()
+	for _, msg := range maasNotifications {
+		msg := msg
+		steps = append(steps, func() {
+			s.notify(n.Id, LEVEL_INFO, msg)
+		})
+	}
+	steps = append(steps, func() {
+		n.apps[APP_MAAS] = true
+		n.ProvisionStatus = STATUS_READY
+	})
+	s.sequence(steps...)
+}
+
+func (s *Server) updateNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.NodeWithKubernetes
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	n := s.node(w, fmt.Sprint(req.Id))
+	if n == nil {
+		return
+	}
+	if req.Bmc != "" {
+		n.Bmc = req.Bmc
+		n.BmcUser = req.BmcUser
+		n.BmcUsers = req.BmcUsers
+		n.BmcPassword = req.BmcPassword
+	}
+	if req.AdminUser != "" {
+		n.AdminUser = req.AdminUser
+	}
+	if req.SSHKeys != nil {
+		n.SSHKeys = req.SSHKeys
+	}
+	if req.Console != "" {
+		n.Console = req.Console
+	}
+	if req.Managed != nil {
+		n.Managed = req.Managed
+	}
+	if req.RoleIds != nil {
+		_, lldp := n.apps[APP_LLDP]
+		if hasRole(req.RoleIds, ROLE_LLDP) && !lldp {
+			n.apps[APP_LLDP] = false
+			s.installLLDP(n)
+		}
+		_, maas := n.apps[APP_MAAS]
+		if hasRole(req.RoleIds, ROLE_MAAS) && !maas {
+			n.apps[APP_MAAS] = false
+			n.ProvisionStatus = STATUS_UPDATING
+			s.installMAAS(n)
+		}
+		n.RoleIds = req.RoleIds
+	}
+	s.reply(w, r, n.NodeWithAdditionalFields)
+}
+
+func (s *Server) delNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	n := s.node(w, args[0])
+	if n == nil {
+		return
+	}
+	n.ProvisionStatus = STATUS_DELETING
+	s.sequence(func() {
+		delete(s.nodes, n.Id)
+	})
+	s.reply(w, r, n.NodeWithAdditionalFields)
+}
+
+func (n *fakeNode) iface(id int64) *pcc.InterfaceDetail {
+	for _, iface := range n.Interfaces {
+		if iface.Interface.Id == id {
+			return iface
+		}
+	}
+	return nil
+}
+
+func (s *Server) setIface(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.InterfaceRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	n := s.node(w, fmt.Sprint(req.NodeId))
+	if n == nil {
+		return
+	}
+	iface := n.iface(req.InterfaceId)
+	if iface == nil {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("interface %v not found", req.InterfaceId))
+		return
+	}
+	n.desired[req.InterfaceId] = req
+	iface.Interface.IntfState = pcc.Queued
+	s.reply(w, r, req)
+}
+
+func (s *Server) applyIface(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req map[string]interface{}
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	n := s.node(w, fmt.Sprint(req["nodeId"]))
+	if n == nil {
+		return
+	}
+	desired := n.desired
+	n.desired = make(map[int64]pcc.InterfaceRequest)
+	for id := range desired {
+		n.iface(id).Interface.IntfState = pcc.Updating
+	}
+	s.sequence(func() {
+		for id, req := range desired {
+			applyIfaceRequest(n.iface(id).Interface, req)
+		}
+	})
+	s.reply(w, r, nil)
+}
+
+func applyIfaceRequest(iface *pcc.Interface, req pcc.InterfaceRequest) {
+	iface.Ipv4Addresses = req.Ipv4Addresses
+	iface.Ipv6Addresses = req.Ipv6Addresses
+	iface.Gateway = req.Gateway
+	iface.Autoneg = req.Autoneg == "on"
+	iface.Speed = string(req.Speed)
+	fmt.Sscan(string(req.Mtu), &iface.Mtu)
+	iface.AdminStatus = req.AdminStatus
+	iface.IsManagement = req.IsManagement == "true"
+	iface.ManagedByPcc = req.ManagedByPcc
+	iface.CarrierStatus = req.AdminStatus
+	iface.IntfState = pcc.Ready
+}
+
+func (s *Server) setIfaceAdmin(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.InterfaceRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	n := s.node(w, fmt.Sprint(req.NodeId))
+	if n == nil {
+		return
+	}
+	iface := n.iface(req.InterfaceId)
+	if iface == nil {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("interface %v not found", req.InterfaceId))
+		return
+	}
+	iface.Interface.AdminStatus = args[0]
+	iface.Interface.CarrierStatus = args[0]
+	s.reply(w, r, nil)
+}
+
+func (s *Server) maasDeploy(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.MaasRequest
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	var nodes []*fakeNode
+	for _, id := range req.Nodes {
+		n := s.node(w, fmt.Sprint(id))
+		if n == nil {
+			return
+		}
+		nodes = append(nodes, n)
+	}
+	for _, n := range nodes {
+		n.ProvisionStatus = STATUS_REIMAGING
+	}
+	s.sequence(func() {
+		for _, n := range nodes {
+			n.ProvisionStatus = STATUS_READY
+		}
+	})
+	s.reply(w, r, nil)
+}
+
+func (s *Server) getStorageNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	if n := s.node(w, args[0]); n != nil {
+		s.reply(w, r, pcc.StorageChildrenTO{})
+	}
+}
+
+func (s *Server) getHardwareInventory(w http.ResponseWriter,
+	r *http.Request, args []string) {
+
+	s.reply(w, r, []pcc.HardwareInventory{})
+}
@@ -0,0 +1,165 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+const (
+	LEVEL_INFO  = "info"
+	LEVEL_ERROR = "error"
+
+	DEFAULT_NOTIFICATION_LIMIT = 50
+)
+
+func (s *Server) notificationRoutes() {
+	s.handle("GET", "pccserver/notifications/history", s.getNotifications)
+	s.handleUnlocked("GET", "pccserver/notifications/stream",
+		s.streamNotifications)
+}
+
+// Notify emits a notification as if PCC had raised it for targetId.
+func (s *Server) Notify(targetId uint64, level string, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notify(targetId, level, msg)
+}
+
+func (s *Server) notify(targetId uint64, level string, msg string) {
+	var n pcc.Notification
+
+	n.Id = s.nextId()
+	n.TargetId = targetId
+	n.Level = level
+	n.Message = msg
+	n.CreatedAt = pcc.ConvertToMillis(time.Now())
+	s.notifications = append(s.notifications, n)
+	for stream := range s.streams {
+		select {
+		case stream <- n:
+		default:
+			// a client too slow to follow loses its stream
+			close(stream)
+			delete(s.streams, stream)
+		}
+	}
+}
+
+// BreakStreams closes the notification streams, as a restart of PCC or a
+// proxy timeout would.  Clients may open new ones.
+func (s *Server) BreakStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for stream := range s.streams {
+		close(stream)
+		delete(s.streams, stream)
+	}
+}
+
+// Streams returns how many clients follow the notification stream.
+func (s *Server) Streams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+// streamNotifications sends the notifications as server-sent events as
+// they are raised, unless NoStream is set.  It runs unlocked, only
+// locking the server to register and remove its stream.
+func (s *Server) streamNotifications(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	flusher, ok := w.(http.Flusher)
+	s.mu.Lock()
+	if s.NoStream || !ok {
+		s.mu.Unlock()
+		s.fail(w, http.StatusNotFound, "not found", r.URL.Path)
+		return
+	}
+	stream := make(chan pcc.Notification, 256)
+	s.streams[stream] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, stream)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case n, open := <-stream:
+			if !open {
+				return
+			}
+			data, _ := json.Marshal(n)
+			fmt.Fprintf(w, "event: notification\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// getNotifications returns the history newest first, one page at a
+// time, optionally only from a time in milliseconds, before an id, unless
+// NoCursor, or for a targetId.  Like some PCC releases, it ignores the
+// type filter.  Malformed page
+// or limit fall back to the first page of DEFAULT_NOTIFICATION_LIMIT
+// entries.
+func (s *Server) getNotifications(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	q := r.URL.Query()
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 0 {
+		page = 0
+	}
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = DEFAULT_NOTIFICATION_LIMIT
+	}
+
+	from, _ := strconv.ParseUint(q.Get("from"), 10, 64)
+	targetId, _ := strconv.ParseUint(q.Get("targetId"), 10, 64)
+	beforeId, _ := strconv.ParseUint(q.Get("beforeId"), 10, 64)
+	if s.NoCursor {
+		beforeId = 0
+	}
+	s.startInstall(targetId, from)
+
+	var selected []pcc.Notification
+	for i := len(s.notifications) - 1; i >= 0; i-- {
+		n := s.notifications[i]
+		if n.CreatedAt < from {
+			break
+		}
+		if beforeId != 0 && n.Id >= beforeId {
+			continue
+		}
+		if targetId != 0 && n.TargetId != targetId {
+			continue
+		}
+		selected = append(selected, n)
+	}
+
+	history := []pcc.Notification{}
+	for i := page * limit; i < len(selected) && i < (page+1)*limit; i++ {
+		history = append(history, selected[i])
+	}
+	s.reply(w, r, history)
+}
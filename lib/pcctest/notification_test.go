@@ -0,0 +1,138 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+// waitFor fails t unless cond holds within a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %v", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (s *Server) setNoStream(noStream bool) {
+	s.mu.Lock()
+	s.NoStream = noStream
+	s.mu.Unlock()
+}
+
+func TestHubReconnect(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+	h := p.StartNotificationHub(20 * time.Millisecond)
+	defer h.Close()
+	sub := h.Subscribe()
+	defer sub.Close()
+
+	next := func(want string) {
+		t.Helper()
+		n, err := sub.Next(context.Background(),
+			time.After(5*time.Second))
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", want, err)
+		}
+		if n.Message != want {
+			t.Fatalf("got %q, want %q", n.Message, want)
+		}
+	}
+
+	waitFor(t, "the stream", func() bool {
+		return s.Streams() == 1 && h.Mode() == pcc.HUB_STREAM
+	})
+	s.Notify(1, LEVEL_INFO, "streamed")
+	next("streamed")
+
+	// the stream breaks and can't be opened again for a while
+	s.setNoStream(true)
+	s.BreakStreams()
+	waitFor(t, "polling", func() bool {
+		return h.Mode() == pcc.HUB_POLL
+	})
+	s.Notify(1, LEVEL_INFO, "polled")
+	next("polled")
+
+	s.setNoStream(false)
+	waitFor(t, "the stream to be opened again", func() bool {
+		return s.Streams() == 1 && h.Mode() == pcc.HUB_STREAM
+	})
+	s.Notify(1, LEVEL_INFO, "streamed again")
+	next("streamed again")
+
+	// each notification is passed on once, polled or streamed
+	_, err := sub.Next(context.Background(),
+		time.After(100*time.Millisecond))
+	if !errors.Is(err, pcc.ErrWaitTimeout) {
+		t.Errorf("got %v after the last notification", err)
+	}
+}
+
+func TestHubNoStream(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+	s.setNoStream(true)
+	h := p.StartNotificationHub(20 * time.Millisecond)
+	defer h.Close()
+	sub := h.Subscribe()
+	defer sub.Close()
+
+	waitFor(t, "polling", func() bool {
+		return h.Mode() == pcc.HUB_POLL
+	})
+	s.Notify(1, LEVEL_INFO, "polled")
+	n, err := sub.Next(context.Background(), time.After(5*time.Second))
+	if err != nil || n.Message != "polled" {
+		t.Fatalf("got %q, %v", n.Message, err)
+	}
+	if s.Streams() != 0 || h.Mode() != pcc.HUB_POLL {
+		t.Errorf("%v streams in mode %v without a stream", s.Streams(),
+			h.Mode())
+	}
+}
+
+// TestIterNotificationsInserted reads the history two by two while
+// notifications are raised, from a fake paging by id and from one paging
+// by offset only.
+func TestIterNotificationsInserted(t *testing.T) {
+	for _, noCursor := range []bool{false, true} {
+		s, p := newClient(t, pcc.PccClientConfig{})
+		s.NoCursor = noCursor
+		var want []string
+		for i := 1; i <= 5; i++ {
+			s.Notify(1, LEVEL_INFO, fmt.Sprint("old ", i))
+			want = append([]string{fmt.Sprint("old ", i)}, want...)
+		}
+
+		var got []string
+		it := p.IterNotifications(pcc.NotificationFilter{Limit: 2})
+		for it.Next() {
+			got = append(got, it.Notification().Message)
+			if len(got)%2 == 1 {
+				s.Notify(1, LEVEL_INFO, fmt.Sprint("new ", len(got)))
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("no cursor %v: %v", noCursor, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("no cursor %v: got %q, want %q", noCursor, got,
+				want)
+		}
+		s.Close()
+	}
+}
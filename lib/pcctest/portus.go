@@ -0,0 +1,149 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+func (s *Server) portusRoutes() {
+	s.handle("GET", pcc.PORTUS_ENDPOINT, s.getPortusNodes)
+	s.handle("POST", pcc.PORTUS_ENDPOINT, s.installPortusNode)
+	s.handle("GET", pcc.PORTUS_ENDPOINT+"/([0-9]+)", s.getPortusNodeById)
+	s.handle("DELETE", pcc.PORTUS_ENDPOINT+"/([0-9]+)", s.delPortusNode)
+
+	s.handle("GET", pcc.PROFILE_ENDPOINT, s.getAuthProfiles)
+	s.handle("POST", pcc.PROFILE_ENDPOINT, s.addAuthProfile)
+	s.handle("GET", pcc.PROFILE_ENDPOINT+"/([0-9]+)", s.getAuthProfileById)
+	s.handle("DELETE", pcc.PROFILE_ENDPOINT+"/([0-9]+)", s.delAuthProfile)
+}
+
+func (s *Server) getPortusNodes(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	configs := []*pcc.PortusConfiguration{}
+	for _, p := range s.portus {
+		configs = append(configs, p)
+	}
+	s.reply(w, r, configs)
+}
+
+func (s *Server) installPortusNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var p pcc.PortusConfiguration
+
+	if err := decode(r, &p); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if _, found := s.nodes[p.NodeID]; !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("node %v not found", p.NodeID))
+		return
+	}
+	for _, other := range s.portus {
+		if other.NodeID == p.NodeID {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("portus already installed on %v",
+					p.NodeID))
+			return
+		}
+	}
+	p.ID = s.nextId()
+	s.portus[p.ID] = &p
+	s.sequence(func() {
+		s.notify(p.NodeID, LEVEL_INFO, fmt.Sprintf(
+			"[Portus] has been installed correctly on %v", p.Name))
+	})
+	s.reply(w, r, p)
+}
+
+func (s *Server) getPortusNodeById(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	p, found := s.portus[parseId(args[0])]
+	if !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			"record not found")
+		return
+	}
+	s.reply(w, r, p)
+}
+
+func (s *Server) delPortusNode(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	p, found := s.portus[parseId(args[0])]
+	if !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			"record not found")
+		return
+	}
+	s.sequence(func() {
+		delete(s.portus, p.ID)
+	})
+	s.reply(w, r, p)
+}
+
+func (s *Server) getAuthProfiles(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	profiles := []*pcc.AuthenticationProfile{}
+	for _, p := range s.profiles {
+		profiles = append(profiles, p)
+	}
+	s.reply(w, r, profiles)
+}
+
+func (s *Server) addAuthProfile(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var p pcc.AuthenticationProfile
+
+	if err := decode(r, &p); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	for _, other := range s.profiles {
+		if other.Name == p.Name {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("profile %v already exists",
+					p.Name))
+			return
+		}
+	}
+	p.ID = s.nextId()
+	s.profiles[p.ID] = &p
+	s.reply(w, r, p)
+}
+
+func (s *Server) getAuthProfileById(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	p, found := s.profiles[parseId(args[0])]
+	if !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			"record not found")
+		return
+	}
+	s.reply(w, r, p)
+}
+
+func (s *Server) delAuthProfile(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	p, found := s.profiles[parseId(args[0])]
+	if !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			"record not found")
+		return
+	}
+	delete(s.profiles, p.ID)
+	s.reply(w, r, p)
+}
@@ -0,0 +1,98 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+func TestRetryLimit(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{
+		Retry: pcc.RetryConfig{MaxAttempts: 3, BackoffMs: 1},
+	})
+	defer s.Close()
+
+	s.FailNext(3, http.StatusServiceUnavailable)
+	if _, err := p.GetNodesDetail(); err == nil {
+		t.Error("no error once the attempts are used up")
+	}
+	if n := p.Retries(); n != 2 {
+		t.Errorf("%v retries for 3 attempts, want 2", n)
+	}
+	// the failures were all consumed by the attempts
+	if _, err := p.GetNodesDetail(); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Retries(); n != 2 {
+		t.Errorf("%v retries, want 2", n)
+	}
+}
+
+func TestRetryNotIdempotent(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{
+		Retry: pcc.RetryConfig{BackoffMs: 1},
+	})
+	defer s.Close()
+
+	s.FailNext(1, http.StatusServiceUnavailable)
+	var tenant pcc.Tenant
+	tenant.Name = "retry"
+	tenant.Parent = ROOT_TENANT_ID
+	if err := p.AddTenant(tenant); err == nil {
+		t.Error("no error for a failed POST")
+	}
+	if n := p.Retries(); n != 0 {
+		t.Errorf("POST retried %v times", n)
+	}
+	if err := p.AddTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{
+		Retry: pcc.RetryConfig{BackoffMs: 1000, MaxBackoffMs: 60000},
+	})
+	defer s.Close()
+
+	clock := NewClock(time.Now())
+	p = p.WithClock(clock)
+	s.FailNext(2, http.StatusBadGateway)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.GetNodesDetail()
+		done <- err
+	}()
+
+	// the waits are at most 1s, then 2s, and only end with the clock
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		if !clock.BlockUntil(1, 5*time.Second) {
+			t.Fatalf("no backoff before the retry after %v", d)
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("retried before the backoff ended: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		clock.Advance(d)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not done after the backoffs")
+	}
+	if n := p.Retries(); n != 2 {
+		t.Errorf("%v retries, want 2", n)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+func (s *Server) securityRoutes() {
+	s.handle("POST", "security/auth", s.authenticate)
+
+	s.handle("POST", "key-manager/keys/upload/(public|private)/([^/]+)",
+		s.uploadKey)
+	s.handle("GET", "key-manager/keys/describe", s.getKeys)
+	s.handle("GET", "key-manager/keys/describe/([^/]+)", s.getKey)
+	s.handle("DELETE", "key-manager/keys/([^/]+)", s.deleteKey)
+
+	s.handle("POST", "key-manager/certificates/upload/([^/]+)",
+		s.uploadCert)
+	s.handle("GET", "key-manager/certificates/describe", s.getCerts)
+	s.handle("GET", "key-manager/certificates/describe/([0-9]+)",
+		s.getCert)
+	s.handle("DELETE", "key-manager/certificates/([0-9]+)", s.deleteCert)
+}
+
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var cred pcc.Credential
+
+	if err := decode(r, &cred); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	u, found := s.users[cred.UserName]
+	if !found || u.password != cred.Password || !u.Active {
+		w.Header().Set("Message", "authentication failed")
+		w.Header().Set("Error", "invalid username or password")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.raw(w, map[string]string{"token": s.token})
+}
+
+// upload reads the multipart form sent by UploadKey and UploadCert.
+func upload(r *http.Request) (fileName string, description string,
+	err error) {
+
+	if err = r.ParseMultipartForm(1 << 20); err != nil {
+		return
+	}
+	_, header, err := r.FormFile("file")
+	if err != nil {
+		return
+	}
+	fileName = header.Filename
+	description = r.FormValue("description")
+	return
+}
+
+func (s *Server) findKey(alias string) *pcc.SecurityKey {
+	for _, k := range s.keys {
+		if k.Alias == alias {
+			return k
+		}
+	}
+	return nil
+}
+
+func (s *Server) uploadKey(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	fileName, description, err := upload(r)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if s.findKey(args[1]) != nil {
+		s.fail(w, http.StatusConflict, "conflict",
+			fmt.Sprintf("key %v already exists", args[1]))
+		return
+	}
+	key := &pcc.SecurityKey{
+		Id:          s.nextId(),
+		Name:        fileName,
+		Alias:       args[1],
+		Type:        args[0],
+		Description: description,
+		Tenant:      ROOT_TENANT_ID,
+	}
+	s.keys[key.Id] = key
+	s.raw(w, key)
+}
+
+func (s *Server) getKeys(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	keys := []*pcc.SecurityKey{}
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	s.raw(w, keys)
+}
+
+func (s *Server) getKey(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	key := s.findKey(args[0])
+	if key == nil {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("key %v not found", args[0]))
+		return
+	}
+	s.raw(w, key)
+}
+
+// deleteKey accepts either the alias or the id of the key.
+func (s *Server) deleteKey(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	key := s.findKey(args[0])
+	if key == nil {
+		if id, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+			key = s.keys[id]
+		}
+	}
+	if key == nil {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("key %v not found", args[0]))
+		return
+	}
+	delete(s.keys, key.Id)
+	s.raw(w, key)
+}
+
+func (s *Server) uploadCert(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	fileName, description, err := upload(r)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	cert := &pcc.Certificate{
+		Id:          s.nextId(),
+		Name:        fileName,
+		Alias:       args[0],
+		Description: description,
+		Tenant:      ROOT_TENANT_ID,
+	}
+	s.certs[cert.Id] = cert
+	s.raw(w, cert)
+}
+
+func (s *Server) getCerts(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	certs := []*pcc.Certificate{}
+	for _, c := range s.certs {
+		certs = append(certs, c)
+	}
+	s.raw(w, certs)
+}
+
+func (s *Server) getCert(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	id := parseId(args[0])
+	cert, found := s.certs[id]
+	if !found {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("certificate %v not found", id))
+		return
+	}
+	s.raw(w, cert)
+}
+
+func (s *Server) deleteCert(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	id := parseId(args[0])
+	cert, found := s.certs[id]
+	if !found {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("certificate %v not found", id))
+		return
+	}
+	delete(s.certs, id)
+	s.raw(w, cert)
+}
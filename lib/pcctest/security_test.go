@@ -0,0 +1,86 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"sync"
+	"testing"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+// newClient starts a fake PCC and returns a client authenticated to it.
+func newClient(t *testing.T, config pcc.PccClientConfig) (*Server,
+	*pcc.PccClient) {
+
+	s := NewServer()
+	config.TLS.Insecure = true
+	p, err := pcc.AuthenticateURL(s.URL, pcc.Credential{
+		UserName: DEFAULT_USER,
+		Password: DEFAULT_PASSWORD,
+	}, config)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	return s, p
+}
+
+func TestTokenRefresh(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+
+	var (
+		mu   sync.Mutex
+		seen []uint
+	)
+	// the hook calls back into p, which must not deadlock
+	p.OnTokenRefresh(func(err error) {
+		if err != nil {
+			t.Errorf("re-login failed: %v", err)
+		}
+		mu.Lock()
+		seen = append(seen, p.TokenRefreshes())
+		mu.Unlock()
+	})
+
+	s.ExpireToken()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.GetNodesDetail(); err != nil {
+				t.Errorf("GetNodesDetail: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := p.TokenRefreshes(); n != 1 {
+		t.Errorf("%v re-logins for one expired token, want 1", n)
+	}
+	if len(seen) != 1 || seen[0] != 1 {
+		t.Errorf("hook saw %v, want [1]", seen)
+	}
+
+	// the new token is kept for the following requests
+	if _, err := p.GetUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.TokenRefreshes(); n != 1 {
+		t.Errorf("%v re-logins without expiry, want 1", n)
+	}
+}
+
+func TestHost(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+
+	// from the URL, as no PccIp is given with one
+	if host := p.Host(); host != s.Host() {
+		t.Errorf("Host() = %q, want %q", host, s.Host())
+	}
+}
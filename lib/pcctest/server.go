@@ -0,0 +1,338 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package pcctest provides an in-memory PCC that speaks enough of the
+// PCC REST API to exercise the pcc package, and the blackbox suites
+// built on it, without a live appliance.
+package pcctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+const (
+	DEFAULT_DELAY    = 2 * time.Second
+	DEFAULT_USER     = "admin"
+	DEFAULT_PASSWORD = "admin"
+	ROOT_TENANT_ID   = 1
+)
+
+type route struct {
+	method  string
+	pattern *regexp.Regexp
+	handler func(w http.ResponseWriter, r *http.Request, args []string)
+	// the handler locks the server itself, as it runs for long
+	unlocked bool
+}
+
+// Server is a fake PCC.  Long running operations (node add, app
+// install, cluster deploy, ...) advance one state every Delay and emit
+// the same notifications a real PCC does.
+type Server struct {
+	// numbers the failed requests, as the request id of the reply;
+	// first for the alignment of atomic operations
+	failures uint64
+
+	*httptest.Server
+
+	// time between two state transitions of a long running operation
+	Delay time.Duration
+	// answer the notification stream with a 404, as the PCC releases
+	// without one
+	NoStream bool
+	// page the notification history by offset only, ignoring beforeId
+	NoCursor bool
+
+	basePath string
+
+	mu      sync.Mutex
+	done    chan struct{}
+	routes  []route
+	token   string
+	faults  []int
+	lastId  uint64
+	users   map[string]*fakeUser
+	tenants map[uint64]*pcc.Tenant
+
+	nodes         map[uint64]*fakeNode
+	pendingIfaces map[string][]*pcc.InterfaceDetail
+	failHosts     map[string]bool
+	notifications []pcc.Notification
+	streams       map[chan pcc.Notification]bool
+
+	k8sClusters  map[uint64]*pcc.K8sCluster
+	cephClusters map[uint64]*fakeCephCluster
+	cephPools    map[uint64]*fakeCephPool
+	cephFS       map[uint64]*fakeCephFS
+
+	portus   map[uint64]*pcc.PortusConfiguration
+	profiles map[uint64]*pcc.AuthenticationProfile
+	sites    map[uint64]*pcc.Site
+	keys     map[uint64]*pcc.SecurityKey
+	certs    map[uint64]*pcc.Certificate
+}
+
+// NewServer starts a fake PCC over TLS on a random local port.
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// NewServerAt starts a fake PCC over TLS on addr, e.g. "127.0.0.1:9999",
+// so it can be reached the same way as an appliance.
+func NewServerAt(addr string) (s *Server, err error) {
+	return NewServerURL("https://" + addr)
+}
+
+// NewServerURL starts a fake PCC answering at base, e.g.
+// "http://127.0.0.1:8080/pcc", over TLS or plain HTTP as the scheme
+// says.
+func NewServerURL(base string) (s *Server, err error) {
+	var (
+		u *url.URL
+		l net.Listener
+	)
+
+	if u, err = url.Parse(base); err != nil {
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		err = fmt.Errorf("unsupported scheme %v", u.Scheme)
+		return
+	}
+	if l, err = net.Listen("tcp", u.Host); err != nil {
+		return
+	}
+	s = newServer()
+	s.basePath = strings.TrimSuffix(u.Path, "/")
+	s.Server = httptest.NewUnstartedServer(s)
+	s.Server.Listener.Close()
+	s.Server.Listener = l
+	if u.Scheme == "https" {
+		s.Server.StartTLS()
+	} else {
+		s.Server.Start()
+	}
+	return
+}
+
+func newServer() (s *Server) {
+	s = &Server{
+		Delay:         DEFAULT_DELAY,
+		done:          make(chan struct{}),
+		token:         newToken(),
+		users:         make(map[string]*fakeUser),
+		tenants:       make(map[uint64]*pcc.Tenant),
+		nodes:         make(map[uint64]*fakeNode),
+		pendingIfaces: make(map[string][]*pcc.InterfaceDetail),
+		failHosts:     make(map[string]bool),
+		streams:       make(map[chan pcc.Notification]bool),
+		k8sClusters:   make(map[uint64]*pcc.K8sCluster),
+		cephClusters:  make(map[uint64]*fakeCephCluster),
+		cephPools:     make(map[uint64]*fakeCephPool),
+		cephFS:        make(map[uint64]*fakeCephFS),
+		portus:        make(map[uint64]*pcc.PortusConfiguration),
+		profiles:      make(map[uint64]*pcc.AuthenticationProfile),
+		sites:         make(map[uint64]*pcc.Site),
+		keys:          make(map[uint64]*pcc.SecurityKey),
+		certs:         make(map[uint64]*pcc.Certificate),
+		lastId:        ROOT_TENANT_ID,
+	}
+
+	root := &pcc.Tenant{}
+	root.ID = ROOT_TENANT_ID
+	root.Name = "ROOT"
+	s.tenants[root.ID] = root
+	s.addUser(pcc.AddUser{
+		UserName: DEFAULT_USER,
+		Password: DEFAULT_PASSWORD,
+		TenantId: ROOT_TENANT_ID,
+		RoleId:   1,
+		Active:   true,
+		Protect:  true,
+	})
+
+	s.securityRoutes()
+	s.userManagementRoutes()
+	s.nodeRoutes()
+	s.notificationRoutes()
+	s.kubernetesRoutes()
+	s.cephRoutes()
+	s.portusRoutes()
+	s.siteRoutes()
+	return
+}
+
+// Close stops pending state transitions and shuts the server down.
+func (s *Server) Close() {
+	close(s.done)
+	s.Server.Close()
+}
+
+// Host returns the address PCC is listening on, without the port.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+func (s *Server) handle(method string, pattern string,
+	handler func(w http.ResponseWriter, r *http.Request, args []string)) {
+
+	s.routes = append(s.routes, route{
+		method:  method,
+		pattern: regexp.MustCompile("^/" + pattern + "$"),
+		handler: handler,
+	})
+}
+
+// handleUnlocked is handle for a handler that locks the server itself.
+func (s *Server) handleUnlocked(method string, pattern string,
+	handler func(w http.ResponseWriter, r *http.Request, args []string)) {
+
+	s.handle(method, pattern, handler)
+	s.routes[len(s.routes)-1].unlocked = true
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, s.basePath)
+	path = strings.TrimSuffix(path, "/")
+	for _, rt := range s.routes {
+		args := rt.pattern.FindStringSubmatch(path)
+		if args == nil || rt.method != r.Method {
+			continue
+		}
+		if !s.admit(w, r, path) {
+			return
+		}
+		if rt.unlocked {
+			rt.handler(w, r, args[1:])
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		rt.handler(w, r, args[1:])
+		return
+	}
+	s.fail(w, http.StatusNotFound, "not found",
+		fmt.Sprintf("no route for %v %v", r.Method, r.URL.Path))
+}
+
+// admit answers r with the failure injected next, if any, or with a 401
+// if it lacks the token, and tells whether it can be handled.
+func (s *Server) admit(w http.ResponseWriter, r *http.Request,
+	path string) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.faults) > 0 {
+		status := s.faults[0]
+		s.faults = s.faults[1:]
+		s.fail(w, status, http.StatusText(status), "injected failure")
+		return false
+	}
+	if path != "/security/auth" && !s.authorized(r) {
+		s.fail(w, http.StatusUnauthorized, "unauthorized",
+			"invalid or missing token")
+		return false
+	}
+	return true
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer "+s.token
+}
+
+// ExpireToken invalidates the token handed out so far, as PCC does when
+// a session times out.  Clients have to authenticate again.
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	s.token = newToken()
+	s.mu.Unlock()
+}
+
+// FailNext answers the next n requests with status, as an overloaded
+// gateway would.
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	for i := 0; i < n; i++ {
+		s.faults = append(s.faults, status)
+	}
+	s.mu.Unlock()
+}
+
+func newToken() string {
+	return fmt.Sprintf("fake-%d", time.Now().UnixNano())
+}
+
+func (s *Server) nextId() uint64 {
+	s.lastId++
+	return s.lastId
+}
+
+// sequence runs steps one Delay apart, each with the server locked.
+func (s *Server) sequence(steps ...func()) {
+	go func() {
+		for _, step := range steps {
+			select {
+			case <-s.done:
+				return
+			case <-time.After(s.Delay):
+			}
+			s.mu.Lock()
+			step()
+			s.mu.Unlock()
+		}
+	}()
+}
+
+func decode(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// reply writes the pccserver envelope used by the gateway services.
+func (s *Server) reply(w http.ResponseWriter, r *http.Request,
+	data interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":   r.URL.Path,
+		"status": http.StatusOK,
+		"data":   data,
+	})
+}
+
+// fail writes an error envelope with status both in the header and in
+// the body, as PCC does.
+func (s *Server) fail(w http.ResponseWriter, status int, message string,
+	errStr string) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(pcc.REQUEST_ID_HEADER,
+		fmt.Sprint(atomic.AddUint64(&s.failures, 1)))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"message": message,
+		"error":   errStr,
+	})
+}
+
+// raw writes v as is, for services that do not use the envelope.
+func (s *Server) raw(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+func (s *Server) siteRoutes() {
+	s.handle("GET", "pccserver/site", s.getSites)
+	s.handle("POST", "pccserver/site/add", s.addSite)
+	s.handle("POST", "pccserver/site/delete", s.delSite)
+}
+
+func (s *Server) getSites(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	sites := []*pcc.Site{}
+	for _, site := range s.sites {
+		sites = append(sites, site)
+	}
+	s.reply(w, r, sites)
+}
+
+func (s *Server) addSite(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var site pcc.Site
+
+	if err := decode(r, &site); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	for _, other := range s.sites {
+		if other.Name == site.Name {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("site %v already exists",
+					site.Name))
+			return
+		}
+	}
+	site.Id = s.nextId()
+	s.sites[site.Id] = &site
+	s.reply(w, r, site)
+}
+
+func (s *Server) delSite(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var ids []uint64
+
+	if err := decode(r, &ids); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	for _, id := range ids {
+		if _, found := s.sites[id]; !found {
+			s.fail(w, http.StatusBadRequest, "bad request",
+				fmt.Sprintf("site %v not found", id))
+			return
+		}
+	}
+	for _, id := range ids {
+		delete(s.sites, id)
+	}
+	s.reply(w, r, ids)
+}
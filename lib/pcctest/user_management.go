@@ -0,0 +1,219 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"fmt"
+	"net/http"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+type fakeUser struct {
+	pcc.User
+	password string
+	roleId   uint64
+}
+
+func (s *Server) userManagementRoutes() {
+	s.handle("POST", "user-management/tenant/register", s.addTenant)
+	s.handle("POST", "user-management/tenant/delete", s.delTenant)
+	s.handle("GET", "user-management/tenant/list", s.getTenants)
+	s.handle("POST", "user-management/tenant/nodes/update",
+		s.assignTenantNodes)
+
+	s.handle("GET", "user-management/user/list", s.getUsers)
+	s.handle("POST", "user-management/user/register", s.registerUser)
+	s.handle("POST", "user-management/user/update", s.updateUser)
+	s.handle("POST", "user-management/user/delete", s.delUser)
+}
+
+func (s *Server) findTenant(name string) *pcc.Tenant {
+	for _, t := range s.tenants {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Server) addTenant(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var tenant pcc.Tenant
+
+	if err := decode(r, &tenant); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if s.findTenant(tenant.Name) != nil {
+		s.fail(w, http.StatusConflict, "conflict",
+			fmt.Sprintf("tenant %v already exists", tenant.Name))
+		return
+	}
+	if _, found := s.tenants[tenant.Parent]; !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("parent tenant %v not found",
+				tenant.Parent))
+		return
+	}
+	tenant.ID = s.nextId()
+	s.tenants[tenant.ID] = &tenant
+	s.raw(w, tenant)
+}
+
+func (s *Server) delTenant(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var tenant pcc.Tenant
+
+	if err := decode(r, &tenant); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if tenant.ID == ROOT_TENANT_ID {
+		s.fail(w, http.StatusForbidden, "forbidden",
+			"ROOT tenant can't be deleted")
+		return
+	}
+	if _, found := s.tenants[tenant.ID]; !found {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("tenant %v not found", tenant.ID))
+		return
+	}
+	delete(s.tenants, tenant.ID)
+	s.raw(w, tenant)
+}
+
+func (s *Server) getTenants(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	tenants := []*pcc.Tenant{}
+	for _, t := range s.tenants {
+		tenants = append(tenants, t)
+	}
+	s.raw(w, tenants)
+}
+
+func (s *Server) assignTenantNodes(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.ChangeTenant
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if _, found := s.tenants[req.TenantId]; !found {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("tenant %v not found", req.TenantId))
+		return
+	}
+	for _, id := range req.NodeIds {
+		if n, found := s.nodes[id]; found {
+			n.tenant = req.TenantId
+		}
+	}
+	s.raw(w, req)
+}
+
+func (s *Server) addUser(req pcc.AddUser) (u *fakeUser) {
+	u = &fakeUser{password: req.Password, roleId: req.RoleId}
+	u.Id = s.nextId()
+	u.UserName = req.UserName
+	u.Active = req.Active
+	u.Protect = req.Protect
+	u.Tenant = *s.tenants[req.TenantId]
+	u.Profile = pcc.Profile{
+		Id:        u.Id,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     req.Email,
+		Source:    req.Source,
+	}
+	s.users[u.UserName] = u
+	return
+}
+
+func (s *Server) getUsers(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	users := []pcc.User{}
+	for _, u := range s.users {
+		users = append(users, u.User)
+	}
+	s.raw(w, users)
+}
+
+func (s *Server) registerUser(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.AddUser
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if _, found := s.users[req.UserName]; found {
+		s.fail(w, http.StatusConflict, "conflict",
+			fmt.Sprintf("user %v already exists", req.UserName))
+		return
+	}
+	if _, found := s.tenants[req.TenantId]; !found {
+		s.fail(w, http.StatusBadRequest, "bad request",
+			fmt.Sprintf("tenant %v not found", req.TenantId))
+		return
+	}
+	s.raw(w, s.addUser(req).User)
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.AddUser
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	u, found := s.users[req.UserName]
+	if !found {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("user %v not found", req.UserName))
+		return
+	}
+	u.Active = req.Active
+	u.FirstName = req.FirstName
+	u.LastName = req.LastName
+	u.Email = req.Email
+	if req.Password != "" {
+		u.password = req.Password
+	}
+	s.raw(w, u.User)
+}
+
+func (s *Server) delUser(w http.ResponseWriter, r *http.Request,
+	args []string) {
+
+	var req pcc.DelUser
+
+	if err := decode(r, &req); err != nil {
+		s.fail(w, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	u, found := s.users[req.UserName]
+	if !found {
+		s.fail(w, http.StatusNotFound, "not found",
+			fmt.Sprintf("user %v not found", req.UserName))
+		return
+	}
+	if u.Protect {
+		s.fail(w, http.StatusForbidden, "forbidden",
+			fmt.Sprintf("user %v is protected", req.UserName))
+		return
+	}
+	delete(s.users, req.UserName)
+	s.raw(w, u.User)
+}
@@ -0,0 +1,167 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcctest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+// advanceUntil moves clock step forward every time the wait sleeps on it,
+// until done, and returns how many steps it took.
+func advanceUntil(t *testing.T, clock *Clock, step time.Duration,
+	done <-chan struct{}) (steps int) {
+
+	t.Helper()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if !clock.BlockUntil(1, 5*time.Second) {
+			select {
+			case <-done:
+				return
+			default:
+				t.Fatalf("no wait on the clock after %v steps", steps)
+			}
+		}
+		clock.Advance(step)
+		steps++
+		// let the wait poll before it sleeps again
+		select {
+		case <-done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// notify raises msgs for node in order, a few milliseconds apart so that
+// their times tell their order.
+func notify(s *Server, node uint64, msgs ...string) {
+	for _, msg := range msgs {
+		time.Sleep(3 * time.Millisecond)
+		s.Notify(node, LEVEL_INFO, msg)
+	}
+	time.Sleep(3 * time.Millisecond)
+}
+
+func TestWaitForEventTimeout(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+	clock := NewClock(time.Now())
+	p = p.WithClock(clock)
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err = p.WaitForEvent(time.Now(), pcc.EventWaiter{
+			Success: []string{"never"},
+			Timeout: 10 * time.Minute,
+			Period:  10 * time.Second,
+		})
+	}()
+	steps := advanceUntil(t, clock, 10*time.Second, done)
+
+	if !errors.Is(err, pcc.ErrWaitTimeout) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if steps != 60 {
+		t.Errorf("timed out after %v polls of 10s, want 60", steps)
+	}
+}
+
+func TestWaitForEventFailureFirst(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+	clock := NewClock(time.Now())
+	p = p.WithClock(clock)
+
+	from := time.Now()
+	notify(s, 1, "agent install failed", "agent installed")
+	_, err := p.WaitForEvent(from, pcc.EventWaiter{
+		NodeId:  1,
+		Success: []string{"agent installed"},
+		Failure: []string{"failed"},
+		Timeout: time.Minute,
+	})
+
+	var failure *pcc.EventFailure
+	if !errors.As(err, &failure) {
+		t.Fatalf("got %v, want the failure raised first", err)
+	}
+	if msg := failure.Notification.Message; msg != "agent install failed" {
+		t.Errorf("failed by %q", msg)
+	}
+	if n := clock.Waiters(); n != 0 {
+		t.Errorf("%v waits left on the clock", n)
+	}
+}
+
+var installSequence = pcc.Sequence{
+	Name:   "install",
+	NodeId: 1,
+	Milestones: []pcc.Milestone{
+		{Name: "started", Message: "install started"},
+		{Name: "configured", Message: "install configured"},
+		{Name: "done", Message: "install done"},
+	},
+	Timeout: time.Minute,
+	Period:  10 * time.Second,
+}
+
+func TestWaitForSequence(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+	p = p.WithClock(NewClock(time.Now()))
+
+	from := time.Now()
+	notify(s, 1, "install started", "install configured", "install done")
+	r, err := p.WaitForSequence(from, installSequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Reached() != 3 || r.Stalled != -1 {
+		t.Errorf("%v", r.String())
+	}
+}
+
+func TestWaitForSequenceOutOfOrder(t *testing.T) {
+	s, p := newClient(t, pcc.PccClientConfig{})
+	defer s.Close()
+	clock := NewClock(time.Now())
+	p = p.WithClock(clock)
+
+	from := time.Now()
+	notify(s, 1, "install configured", "install started", "install done")
+
+	var (
+		r   pcc.SequenceResult
+		err error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r, err = p.WaitForSequence(from, installSequence)
+	}()
+	steps := advanceUntil(t, clock, 10*time.Second, done)
+
+	if !errors.Is(err, pcc.ErrWaitTimeout) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	// configured was raised before started, so it is never seen
+	if r.Stalled != 1 || r.Reached() != 1 {
+		t.Errorf("%v", r.String())
+	}
+	if steps != 6 {
+		t.Errorf("timed out after %v polls of 10s, want 6", steps)
+	}
+}
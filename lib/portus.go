@@ -29,10 +29,6 @@ func (p *PccClient) GetPortusNodes() (portusConfigs []PortusConfiguration, err e
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	err = json.Unmarshal(resp.Data, &portusConfigs)
 	if err != nil {
 		return
@@ -52,10 +48,6 @@ func (p *PccClient) GetPortusNodeById(id uint64) (portusConfig PortusConfigurati
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	err = json.Unmarshal(resp.Data, &portusConfig)
 	if err != nil {
 		return
@@ -66,19 +58,14 @@ func (p *PccClient) GetPortusNodeById(id uint64) (portusConfig PortusConfigurati
 func (p *PccClient) DelPortusNode(id uint64, removeStorage bool) (err error) {
 	var (
 		endpoint string
-		resp     HttpResp
 	)
 
 	endpoint = fmt.Sprintf("%v/%v?removeStorage=%v", PORTUS_ENDPOINT, id,
 		removeStorage)
-	resp, _, err = p.pccGateway("DELETE", endpoint, nil)
+	_, _, err = p.pccGateway("DELETE", endpoint, nil)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	return
 }
 
@@ -86,7 +73,6 @@ func (p *PccClient) InstallPortusNode(portusConfig PortusConfiguration) (err err
 
 	var (
 		data []byte
-		resp HttpResp
 	)
 
 	data, err = json.Marshal(portusConfig)
@@ -94,13 +80,9 @@ func (p *PccClient) InstallPortusNode(portusConfig PortusConfiguration) (err err
 		return
 	}
 
-	resp, _, err = p.pccGateway("POST", PORTUS_ENDPOINT, data)
+	_, _, err = p.pccGateway("POST", PORTUS_ENDPOINT, data)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v: %v", resp.Error, resp.Message)
-		return
-	}
 	return
 }
@@ -32,7 +32,6 @@ type LDAPConfiguration struct {
 func (p *PccClient) AddAuthProfile(authProfile AuthenticationProfile) (err error) {
 	var (
 		data []byte
-		resp HttpResp
 	)
 
 	data, err = json.Marshal(authProfile)
@@ -40,15 +39,10 @@ func (p *PccClient) AddAuthProfile(authProfile AuthenticationProfile) (err error
 		return
 	}
 
-	resp, _, err = p.pccGateway("POST", PROFILE_ENDPOINT, data)
+	_, _, err = p.pccGateway("POST", PROFILE_ENDPOINT, data)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("add Authenticatiom Profile %v failed: %v\n",
-			authProfile.Name, resp.Error)
-		return
-	}
 	return
 }
 
@@ -61,12 +55,7 @@ func (p *PccClient) GetAuthProfiles() (authProfiles []AuthenticationProfile, err
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, &authProfiles)
-		return
-	}
-
-	err = fmt.Errorf("%v", resp.Error)
+	err = json.Unmarshal(resp.Data, &authProfiles)
 	return
 }
 
@@ -82,12 +71,7 @@ func (p *PccClient) GetAuthProfileById(id uint64) (authProfile AuthenticationPro
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		err = json.Unmarshal(resp.Data, &authProfile)
-		return
-	}
-
-	err = fmt.Errorf("%v", resp.Error)
+	err = json.Unmarshal(resp.Data, &authProfile)
 	return
 
 }
@@ -102,39 +86,30 @@ func (p *PccClient) GetAuthProfileByName(name string) (authProfile *Authenticati
 	if err != nil {
 		return
 	}
-	if resp.Status == 200 {
-		if err = json.Unmarshal(resp.Data, &authProfiles); err == nil {
-			for i := range authProfiles {
-				if authProfiles[i].Name == name {
-					authProfile = &authProfiles[i]
-					return
-				}
-			}
-		} else {
+	if err = json.Unmarshal(resp.Data, &authProfiles); err != nil {
+		return
+	}
+	for i := range authProfiles {
+		if authProfiles[i].Name == name {
+			authProfile = &authProfiles[i]
 			return
 		}
 	}
-
-	err = fmt.Errorf("%v", resp.Error)
+	err = fmt.Errorf("authentication profile %v not found", name)
 	return
 
 }
 
 func (p *PccClient) DelAuthProfile(id uint64) (err error) {
 	var (
-		resp     HttpResp
 		endpoint string
 	)
 
 	endpoint = fmt.Sprintf("%v/%v", PROFILE_ENDPOINT, id)
 
-	resp, _, err = p.pccGateway("DELETE", endpoint, nil)
+	_, _, err = p.pccGateway("DELETE", endpoint, nil)
 	if err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	return
 }
@@ -0,0 +1,142 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_RETRY_ATTEMPTS    = 3
+	DEFAULT_RETRY_BACKOFF_MS  = 500
+	DEFAULT_RETRY_MAX_BACKOFF = 10000
+)
+
+var (
+	DEFAULT_RETRY_METHODS  = []string{"GET", "HEAD", "PUT", "DELETE"}
+	DEFAULT_RETRY_STATUSES = []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+)
+
+// RetryConfig says which requests are sent again and how long to wait
+// in between.  The wait starts at BackoffMs milliseconds, doubles after
+// every attempt up to MaxBackoffMs, and is randomly shortened by up to
+// half so that clients don't retry in lockstep.  Unset fields select
+// the defaults, MaxAttempts 1 disables retries.
+type RetryConfig struct {
+	MaxAttempts  uint16
+	BackoffMs    uint16
+	MaxBackoffMs uint16
+	Methods      []string
+	Statuses     []int
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DEFAULT_RETRY_ATTEMPTS
+	}
+	if c.BackoffMs == 0 {
+		c.BackoffMs = DEFAULT_RETRY_BACKOFF_MS
+	}
+	if c.MaxBackoffMs == 0 {
+		c.MaxBackoffMs = DEFAULT_RETRY_MAX_BACKOFF
+	}
+	if c.MaxBackoffMs < c.BackoffMs {
+		c.MaxBackoffMs = c.BackoffMs
+	}
+	if len(c.Methods) == 0 {
+		c.Methods = DEFAULT_RETRY_METHODS
+	}
+	if len(c.Statuses) == 0 {
+		c.Statuses = DEFAULT_RETRY_STATUSES
+	}
+	return c
+}
+
+// retryable tells whether a request of method op that ended with r, or
+// err when no response was received, is worth sending again.
+func (c RetryConfig) retryable(op string, r *http.Response,
+	err error) bool {
+
+	found := false
+	for _, m := range c.Methods {
+		if strings.EqualFold(m, op) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, ErrNotRecorded)
+	}
+	for _, s := range c.Statuses {
+		if r.StatusCode == s {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the attempt following attempt.
+func (c RetryConfig) backoff(attempt uint16) time.Duration {
+	d := time.Duration(c.BackoffMs) * time.Millisecond
+	max := time.Duration(c.MaxBackoffMs) * time.Millisecond
+	for i := uint16(1); i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// withRetry calls do until it succeeds, fails in a way that is not
+// retryable, or the attempts of the policy are used up.
+func (p *PccClient) withRetry(op string, endPoint string,
+	do func() (*http.Response, []byte, error)) (r *http.Response,
+	body []byte, err error) {
+
+	for attempt := uint16(1); ; attempt++ {
+		r, body, err = do()
+		if attempt >= p.retry.MaxAttempts ||
+			!p.retry.retryable(op, r, err) ||
+			p.Context().Err() != nil {
+			return
+		}
+
+		reason := fmt.Sprint(err)
+		if err == nil {
+			reason = r.Status
+		}
+		delay := p.retry.backoff(attempt)
+		p.logf(LOG_WARN, Fields{"endpoint": endPoint},
+			"%v %v: %v, retry %v/%v in %v", op, endPoint, reason,
+			attempt, p.retry.MaxAttempts-1, delay)
+		p.session.mu.Lock()
+		p.session.retries++
+		p.session.mu.Unlock()
+		p.observeRetry(op, endPoint)
+		if p.Sleep(delay) != nil {
+			return
+		}
+	}
+}
+
+// Retries returns how many requests have been sent again so far.
+func (p *PccClient) Retries() uint {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	return p.session.retries
+}
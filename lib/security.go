@@ -5,12 +5,23 @@
 package pcc
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_PCC_PORT         = 9999
+	DEFAULT_DIAL_TIMEOUT     = 10
+	DEFAULT_RESPONSE_TIMEOUT = 60
 )
 
 type Credential struct {
@@ -18,44 +29,285 @@ type Credential struct {
 	Password string `json:"password"`
 }
 
+// PccClientConfig tunes the HTTP transport shared by all the requests
+// of a PccClient.  Timeouts are in seconds, 0 selects the default.
+// BaseURL, e.g. "http://127.0.0.1:8080/pcc", replaces the default
+// https://<PccIp>:9999 for a PCC behind a proxy or a local fake.
+type PccClientConfig struct {
+	BaseURL         string
+	DialTimeout     uint16
+	ResponseTimeout uint16
+	Retry           RetryConfig
+	TLS             TLSConfig
+	Cassette        CassetteConfig
+	LogLevel        string
+	Catalog         CatalogConfig
+}
+
 type PccClient struct {
-	pccIp  string
-	bearer string
+	pccIp       string
+	baseURL     *url.URL
+	client      *http.Client
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	ctx         context.Context
+	retry       RetryConfig
+	session     *pccSession
+	logger      Logger
+	fields      Fields
+	catalog     *Catalog
+	clock       Clock
+}
+
+// pccSession holds the token of a PccClient and what is needed to renew
+// it.  It is shared by all the copies returned by WithContext.
+type pccSession struct {
+	mu        sync.Mutex
+	cred      Credential
+	bearer    string
+	refreshes uint
+	retries   uint
+	onRefresh func(err error)
+	hub       *NotificationHub
+	metrics   *Metrics
+}
+
+func (p *PccClient) newHttpClient(config PccClientConfig) (err error) {
+	if config.DialTimeout == 0 {
+		config.DialTimeout = DEFAULT_DIAL_TIMEOUT
+	}
+	if config.ResponseTimeout == 0 {
+		config.ResponseTimeout = DEFAULT_RESPONSE_TIMEOUT
+	}
+	p.dialTimeout = time.Second * time.Duration(config.DialTimeout)
+	if p.tlsConfig, err = newTLSConfig(config.TLS); err != nil {
+		err = fmt.Errorf("TLS configuration: %v", err)
+		return
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   p.dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     p.tlsConfig,
+		TLSHandshakeTimeout: p.dialTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	p.client = &http.Client{
+		Timeout: time.Second * time.Duration(config.ResponseTimeout),
+	}
+	p.client.Transport, err = newCassetteTransport(config.Cassette,
+		transport)
+	if err != nil {
+		err = fmt.Errorf("cassette: %v", err)
+		return
+	}
+	// a replay runs at the time of the recording
+	if pl, ok := p.client.Transport.(*player); ok {
+		p.clock = pl
+	}
+	return
 }
 
 func Authenticate(PccIp string, cred Credential) (pcc *PccClient, err error) {
+	return AuthenticateWithConfig(PccIp, cred, PccClientConfig{})
+}
+
+func AuthenticateWithConfig(PccIp string, cred Credential,
+	config PccClientConfig) (pcc *PccClient, err error) {
+
+	return AuthenticateContext(context.Background(), PccIp, cred, config)
+}
+
+func AuthenticateContext(ctx context.Context, PccIp string, cred Credential,
+	config PccClientConfig) (pcc *PccClient, err error) {
+
+	p := &PccClient{
+		ctx:     ctx,
+		retry:   config.Retry.withDefaults(),
+		session: &pccSession{cred: cred},
+	}
+	if p.baseURL, err = parseBaseURL(PccIp, config.BaseURL); err != nil {
+		return
+	}
+	p.pccIp = p.baseURL.Hostname()
+	if config.LogLevel != "" {
+		var level LogLevel
+
+		if level, err = ParseLogLevel(config.LogLevel); err != nil {
+			return
+		}
+		p.logger = NewWriterLogger(os.Stdout, level)
+	}
+	if config.Catalog.Release != "" {
+		p.catalog, err = LoadReleaseCatalog(config.Catalog.Dir,
+			config.Catalog.Release)
+		if err != nil {
+			return
+		}
+	}
+	if err = p.newHttpClient(config); err != nil {
+		return
+	}
+	if p.session.bearer, err = p.login(); err != nil {
+		return
+	}
+	pcc = p
+	return
+}
+
+// AuthenticateURL authenticates to the PCC at baseURL, see
+// PccClientConfig.BaseURL.
+func AuthenticateURL(baseURL string, cred Credential,
+	config PccClientConfig) (pcc *PccClient, err error) {
+
+	config.BaseURL = baseURL
+	return AuthenticateWithConfig("", cred, config)
+}
+
+// parseBaseURL checks base, or builds the default URL from PccIp if base
+// is empty.
+func parseBaseURL(PccIp string, base string) (u *url.URL, err error) {
+	if base == "" {
+		base = fmt.Sprintf("https://%v",
+			net.JoinHostPort(PccIp, fmt.Sprint(DEFAULT_PCC_PORT)))
+	}
+	if u, err = url.Parse(base); err != nil {
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		err = fmt.Errorf("invalid PCC URL %v: scheme must be http or "+
+			"https", base)
+		return
+	}
+	if u.Hostname() == "" {
+		err = fmt.Errorf("invalid PCC URL %v: no host", base)
+		return
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+	return
+}
+
+// Host returns the host name or address of PCC, as in its URL.
+func (p *PccClient) Host() string {
+	return p.pccIp
+}
+
+// URL returns the address of path on PCC, e.g. "gui/setPass".
+func (p *PccClient) URL(path string) string {
+	return p.baseURL.String() + "/" + strings.TrimPrefix(path, "/")
+}
+
+// login posts the session credential and returns the new bearer.
+func (p *PccClient) login() (bearer string, err error) {
 	var (
 		data []byte
+		body []byte
 		resp *http.Response
 	)
 
-	data, err = json.Marshal(cred)
-
-	url := fmt.Sprintf("https://%s:9999/security/auth", PccIp)
-	http.DefaultTransport.(*http.Transport).TLSClientConfig =
-		&tls.Config{InsecureSkipVerify: true}
-
-	resp, err = http.Post(url, "application/json", bytes.NewBuffer(data))
+	if data, err = json.Marshal(p.session.cred); err != nil {
+		return
+	}
+	resp, body, err = p.send("POST", "security/auth", "application/json",
+		data, "")
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		err = fmt.Errorf("%v: %v", resp.Header.Get("Message"),
-			resp.Header.Get("Error"))
+		err = newAPIError("POST", "security/auth", resp, body)
 		return
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	var out struct{ Token string }
+	if err = json.Unmarshal(body, &out); err != nil {
 		return
 	}
-	var out struct{ Token string }
-	err = json.Unmarshal(body, &out)
-	if err != nil {
+	bearer = "Bearer " + out.Token
+	return
+}
+
+func (p *PccClient) bearer() string {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	return p.session.bearer
+}
+
+// refresh authenticates again unless the token that was rejected,
+// stale, has already been replaced by a concurrent request.
+func (p *PccClient) refresh(stale string) (err error) {
+	p.session.mu.Lock()
+	if p.session.bearer != stale {
+		p.session.mu.Unlock()
 		return
 	}
-	bearerToken := "Bearer " + out.Token
-	pcc = &PccClient{pccIp: PccIp, bearer: bearerToken}
+	bearer, err := p.login()
+	if err == nil {
+		p.session.bearer = bearer
+		p.session.refreshes++
+	}
+	// the hook may call back into p, so it runs once unlocked
+	onRefresh := p.session.onRefresh
+	p.session.mu.Unlock()
+
+	if onRefresh != nil {
+		onRefresh(err)
+	}
+	return
+}
+
+// Reauthenticate gets a new token for p right away.
+func (p *PccClient) Reauthenticate() error {
+	return p.refresh(p.bearer())
+}
+
+// OnTokenRefresh registers f to be called after every re-authentication,
+// err being the authentication error if any.
+func (p *PccClient) OnTokenRefresh(f func(err error)) {
+	p.session.mu.Lock()
+	p.session.onRefresh = f
+	p.session.mu.Unlock()
+}
+
+// TokenRefreshes returns how many times the token has been renewed.
+func (p *PccClient) TokenRefreshes() uint {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	return p.session.refreshes
+}
+
+// WithContext returns a copy of p whose requests are bound to ctx, so
+// that any call made through it stops when ctx is cancelled or its
+// deadline expires.
+func (p *PccClient) WithContext(ctx context.Context) *PccClient {
+	if ctx == nil {
+		panic("nil context")
+	}
+	p2 := *p
+	p2.ctx = ctx
+	return &p2
+}
+
+// Context returns the context the requests of p are bound to.
+func (p *PccClient) Context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+// Sleep waits for d on the clock of p unless the context of p is done
+// first.
+func (p *PccClient) Sleep(d time.Duration) (err error) {
+	select {
+	case <-p.Clock().After(d):
+	case <-p.Context().Done():
+		err = p.Context().Err()
+	}
 	return
 }
@@ -0,0 +1,145 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Milestone is a notification expected in a workflow, containing
+// Message.  It must be raised within Timeout of the previous one, and
+// any notification containing one of Failure meanwhile fails it.
+type Milestone struct {
+	Name    string
+	Message string
+	Timeout time.Duration
+	Failure []string
+}
+
+// Sequence is a workflow as the ordered milestones it notifies.  NodeId,
+// ClusterId, Failure, ProvisionFailure and FailOnError apply to every
+// milestone, as in an EventWaiter, and Timeout, when set, bounds the
+// whole sequence.
+type Sequence struct {
+	Name             string
+	NodeId           uint64
+	ClusterId        uint64
+	Milestones       []Milestone
+	Failure          []string
+	ProvisionFailure []string
+	FailOnError      bool
+	Timeout          time.Duration
+	Period           time.Duration
+}
+
+// MilestoneResult says when a milestone was notified and how long after
+// the previous one, or the start of the sequence for the first.
+type MilestoneResult struct {
+	Milestone Milestone
+	Reached   bool
+	At        time.Time
+	Duration  time.Duration
+}
+
+// SequenceResult reports how far a sequence went.  Stalled is the index
+// of the milestone that was never reached, -1 when all were.
+type SequenceResult struct {
+	Sequence   string
+	Milestones []MilestoneResult
+	Stalled    int
+	Err        error
+}
+
+// Reached returns how many milestones were reached.
+func (r *SequenceResult) Reached() (n int) {
+	for _, m := range r.Milestones {
+		if m.Reached {
+			n++
+		}
+	}
+	return
+}
+
+// String lists the milestones with their durations, e.g.
+//
+//	MaaS install: 2/3 milestones
+//	  1 Starting role       12s
+//	  2 Dependencies        1m5s
+//	  3 Image repository    stalled: timeout exceeded ...
+func (r *SequenceResult) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%v: %v/%v milestones", r.Sequence, r.Reached(),
+		len(r.Milestones))
+	for i, m := range r.Milestones {
+		fmt.Fprintf(&b, "\n  %v %-40v ", i+1, m.Milestone.Name)
+		switch {
+		case m.Reached:
+			fmt.Fprint(&b, m.Duration)
+		case i == r.Stalled:
+			fmt.Fprintf(&b, "stalled: %v", r.Err)
+		default:
+			fmt.Fprint(&b, "-")
+		}
+	}
+	return b.String()
+}
+
+// WaitForSequence waits for the milestones of s in order, each notified
+// after the previous one, starting from.  The error, also kept in the
+// result, names the milestone that stalled.
+func (p *PccClient) WaitForSequence(from time.Time, s Sequence) (
+	r SequenceResult, err error) {
+
+	r = SequenceResult{Sequence: s.Name, Stalled: -1}
+	for _, m := range s.Milestones {
+		r.Milestones = append(r.Milestones, MilestoneResult{Milestone: m})
+	}
+
+	start := p.Clock().Now()
+	prev := from
+	for i, m := range s.Milestones {
+		var n Notification
+
+		failure := append([]string(nil), s.Failure...)
+		failure = append(failure, m.Failure...)
+		timeout := m.Timeout
+		if s.Timeout != 0 {
+			left := s.Timeout - p.Since(start)
+			if timeout == 0 || left < timeout {
+				timeout = left
+			}
+		}
+		n, err = p.WaitForEvent(prev, EventWaiter{
+			Name:             s.Name,
+			NodeId:           s.NodeId,
+			ClusterId:        s.ClusterId,
+			Success:          []string{m.Message},
+			Failure:          failure,
+			ProvisionFailure: s.ProvisionFailure,
+			FailOnError:      s.FailOnError,
+			Timeout:          timeout,
+			Period:           s.Period,
+		})
+		if err != nil {
+			r.Stalled = i
+			err = fmt.Errorf("%v: milestone %v/%v %q: %w", s.Name, i+1,
+				len(s.Milestones), m.Name, err)
+			r.Err = err
+			return
+		}
+		at := ConvertFromMillis(n.CreatedAt)
+		r.Milestones[i].Reached = true
+		r.Milestones[i].At = at
+		r.Milestones[i].Duration = at.Sub(prev)
+		p.logf(LOG_INFO, Fields{"node": n.TargetId, "sequence": s.Name},
+			"milestone %v/%v %v reached after %v", i+1,
+			len(s.Milestones), m.Name, r.Milestones[i].Duration)
+		prev = at
+	}
+	return
+}
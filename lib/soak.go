@@ -0,0 +1,198 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	SOAK_MEMORY = "memory"
+	// the tasks of the pids cgroup of the container: its processes and
+	// their threads, not the goroutines of a Go service
+	SOAK_PIDS       = "pids/threads"
+	SOAK_FDS        = "fds"
+	SOAK_GOROUTINES = "goroutines"
+
+	// growth over the iterations below which a footprint is steady, in
+	// percent
+	DEFAULT_SOAK_MIN_GROWTH = 5
+	// iterations needed to tell a trend
+	SOAK_MIN_ITERATIONS = 3
+)
+
+// Soak gathers the footprint of the containers at the same point of each
+// iteration of a soak run.
+type Soak struct {
+	// MinGrowth is the growth from the first iteration to the last, in
+	// percent, from which a footprint growing at each iteration is a
+	// leak; DEFAULT_SOAK_MIN_GROWTH when 0
+	MinGrowth uint16
+
+	iterations int
+	containers []string
+	values     map[string]map[string][]float64
+	// the last iteration each container was recorded at
+	seen map[string]int
+}
+
+// SoakTrend is the footprint of a container over the iterations of a
+// soak run, with the slope per iteration of the line fitted to it.  It
+// leaks when it grew at each iteration, by more than MinGrowth overall.
+type SoakTrend struct {
+	Container string
+	Metric    string
+	Values    []float64
+	Slope     float64
+	Growth    float64
+	Leak      bool
+}
+
+func NewSoak() *Soak {
+	return &Soak{
+		values: make(map[string]map[string][]float64),
+		seen:   make(map[string]int),
+	}
+}
+
+// Record adds the samples taken at the end of an iteration.  A
+// container missing from an iteration, e.g. restarted, starts anew.
+func (s *Soak) Record(samples []DockerSample) {
+	s.iterations++
+	for _, sample := range samples {
+		metrics := s.values[sample.Container]
+		if metrics == nil {
+			metrics = make(map[string][]float64)
+			s.values[sample.Container] = metrics
+			s.containers = append(s.containers, sample.Container)
+		}
+		if s.seen[sample.Container] < s.iterations-1 {
+			metrics = make(map[string][]float64)
+			s.values[sample.Container] = metrics
+		}
+		s.seen[sample.Container] = s.iterations
+		metrics[SOAK_MEMORY] = append(metrics[SOAK_MEMORY],
+			float64(sample.Memory))
+		metrics[SOAK_PIDS] = append(metrics[SOAK_PIDS],
+			float64(sample.PIDs))
+		metrics[SOAK_FDS] = append(metrics[SOAK_FDS],
+			float64(sample.FDs))
+		metrics[SOAK_GOROUTINES] = append(metrics[SOAK_GOROUTINES],
+			float64(sample.Goroutines))
+	}
+}
+
+// Iterations returns how many iterations were recorded.
+func (s *Soak) Iterations() int {
+	return s.iterations
+}
+
+// Trends returns the trend of each footprint of each container, by
+// container name.
+func (s *Soak) Trends() (trends []SoakTrend) {
+	minGrowth := float64(s.MinGrowth)
+	if minGrowth == 0 {
+		minGrowth = DEFAULT_SOAK_MIN_GROWTH
+	}
+	containers := append([]string(nil), s.containers...)
+	sort.Strings(containers)
+	for _, c := range containers {
+		for _, metric := range []string{SOAK_MEMORY, SOAK_PIDS,
+			SOAK_FDS, SOAK_GOROUTINES} {
+			values := s.values[c][metric]
+			t := SoakTrend{
+				Container: c,
+				Metric:    metric,
+				Values:    values,
+				Slope:     slope(values),
+			}
+			if len(values) > 0 && values[0] != 0 {
+				t.Growth = (values[len(values)-1] - values[0]) /
+					values[0] * 100
+			}
+			t.Leak = len(values) >= SOAK_MIN_ITERATIONS &&
+				growing(values) && t.Growth > minGrowth
+			if metric != SOAK_MEMORY && metric != SOAK_PIDS &&
+				t.Slope == 0 && values[0] == 0 {
+				// fds not counted by the source, goroutines not for
+				// the container
+				continue
+			}
+			trends = append(trends, t)
+		}
+	}
+	return
+}
+
+// Leaks returns the trends that leak.
+func (s *Soak) Leaks() (leaks []SoakTrend) {
+	for _, t := range s.Trends() {
+		if t.Leak {
+			leaks = append(leaks, t)
+		}
+	}
+	return
+}
+
+// slope is that of the least squares line through values, by index.
+func slope(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	var sx, sy, sxy, sxx float64
+	for i, y := range values {
+		x := float64(i)
+		sx += x
+		sy += y
+		sxy += x * y
+		sxx += x * x
+	}
+	return (n*sxy - sx*sy) / (n*sxx - sx*sx)
+}
+
+// growing tells whether values never decrease and end above their start.
+func growing(values []float64) bool {
+	for i := 1; i < len(values); i++ {
+		if values[i] < values[i-1] {
+			return false
+		}
+	}
+	return values[len(values)-1] > values[0]
+}
+
+func formatSoakValue(metric string, v float64) string {
+	if metric == SOAK_MEMORY {
+		if v < 0 {
+			return "-" + formatSize(uint64(-v))
+		}
+		return formatSize(uint64(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// WriteSoakReport writes trends as a table, the leaks marked.
+func WriteSoakReport(w io.Writer, trends []SoakTrend) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTAINER\tMETRIC\tFIRST\tLAST\tPER ITERATION\t"+
+		"GROWTH\t")
+	for _, t := range trends {
+		if len(t.Values) == 0 {
+			continue
+		}
+		leak := ""
+		if t.Leak {
+			leak = "LEAK"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%.1f%%\t%v\n", t.Container,
+			t.Metric, formatSoakValue(t.Metric, t.Values[0]),
+			formatSoakValue(t.Metric, t.Values[len(t.Values)-1]),
+			formatSoakValue(t.Metric, t.Slope), t.Growth, leak)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,133 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func soakSample(container string, memory uint64, pids, fds int) DockerSample {
+	return DockerSample{
+		Container: container,
+		Memory:    memory,
+		PIDs:      pids,
+		FDs:       fds,
+	}
+}
+
+func soakTrend(t *testing.T, trends []SoakTrend, container,
+	metric string) SoakTrend {
+	t.Helper()
+	for _, trend := range trends {
+		if trend.Container == container && trend.Metric == metric {
+			return trend
+		}
+	}
+	t.Fatalf("no %v trend for %v", metric, container)
+	return SoakTrend{}
+}
+
+func TestSoakTrends(t *testing.T) {
+	s := NewSoak()
+	for i := 0; i < 4; i++ {
+		s.Record([]DockerSample{
+			soakSample("keeper", 100<<20+uint64(i)<<20, 40, 0),
+			soakSample("steady", 100<<20, 40+i%2, 0),
+		})
+	}
+	trends := s.Trends()
+
+	memory := soakTrend(t, trends, "keeper", SOAK_MEMORY)
+	if memory.Slope != 1<<20 {
+		t.Errorf("slope %v, want %v", memory.Slope, 1<<20)
+	}
+	if memory.Growth != 3 {
+		t.Errorf("growth %v, want 3", memory.Growth)
+	}
+	if memory.Leak {
+		t.Errorf("growth of 3%% under the default %v%% is a leak",
+			DEFAULT_SOAK_MIN_GROWTH)
+	}
+	if pids := soakTrend(t, trends, "steady", SOAK_PIDS); pids.Leak {
+		t.Errorf("pids going up and down leak: %v", pids.Values)
+	}
+	for _, trend := range trends {
+		if trend.Metric == SOAK_FDS {
+			t.Errorf("trend of the fds not counted: %+v", trend)
+		}
+	}
+
+	s.MinGrowth = 2
+	leaks := s.Leaks()
+	if len(leaks) != 1 || leaks[0].Container != "keeper" ||
+		leaks[0].Metric != SOAK_MEMORY {
+		t.Errorf("leaks %+v, want the memory of keeper", leaks)
+	}
+}
+
+func TestSoakTooFewIterations(t *testing.T) {
+	s := NewSoak()
+	for i := 0; i < SOAK_MIN_ITERATIONS-1; i++ {
+		s.Record([]DockerSample{soakSample("keeper", 100, 10*(i+1),
+			10*(i+1))})
+	}
+	if leaks := s.Leaks(); len(leaks) != 0 {
+		t.Errorf("leaks after %v iterations: %+v", s.Iterations(), leaks)
+	}
+}
+
+func TestSoakRecordRestart(t *testing.T) {
+	s := NewSoak()
+	s.Record([]DockerSample{soakSample("keeper", 100, 10, 5),
+		soakSample("hub", 100, 10, 5)})
+	s.Record([]DockerSample{soakSample("keeper", 200, 20, 6)})
+	s.Record([]DockerSample{soakSample("keeper", 300, 30, 7),
+		soakSample("hub", 50, 11, 5)})
+	s.Record([]DockerSample{soakSample("keeper", 400, 40, 8),
+		soakSample("hub", 60, 12, 5)})
+
+	trends := s.Trends()
+	hub := soakTrend(t, trends, "hub", SOAK_MEMORY)
+	if want := []float64{50, 60}; !reflect.DeepEqual(hub.Values, want) {
+		t.Errorf("hub memory %v after its restart, want %v",
+			hub.Values, want)
+	}
+	if hub.Leak {
+		t.Errorf("hub leaks over %v iterations", len(hub.Values))
+	}
+	keeper := soakTrend(t, trends, "keeper", SOAK_PIDS)
+	if want := []float64{10, 20, 30, 40}; !reflect.DeepEqual(keeper.Values,
+		want) {
+		t.Errorf("keeper pids %v, want %v", keeper.Values, want)
+	}
+	if !keeper.Leak {
+		t.Errorf("keeper pids %v do not leak", keeper.Values)
+	}
+	if fds := soakTrend(t, trends, "keeper", SOAK_FDS); !fds.Leak {
+		t.Errorf("keeper fds %v do not leak", fds.Values)
+	}
+}
+
+func TestSoakGoroutines(t *testing.T) {
+	s := NewSoak()
+	for i := 0; i < 4; i++ {
+		keeper := soakSample("keeper", 100, 10, 5)
+		keeper.Goroutines = 100 + 20*i
+		s.Record([]DockerSample{keeper, soakSample("hub", 100, 10, 5)})
+	}
+
+	trends := s.Trends()
+	keeper := soakTrend(t, trends, "keeper", SOAK_GOROUTINES)
+	if !keeper.Leak || keeper.Slope != 20 {
+		t.Errorf("keeper goroutines %v, slope %v, want a leak of 20",
+			keeper.Values, keeper.Slope)
+	}
+	for _, trend := range trends {
+		if trend.Container == "hub" && trend.Metric == SOAK_GOROUTINES {
+			t.Errorf("trend of the goroutines not counted: %+v", trend)
+		}
+	}
+}
@@ -25,10 +25,6 @@ func (p *PccClient) GetStorageNode(id uint64) (storage StorageChildrenTO, err er
 	if resp, _, err = p.pccGateway("GET", endpoint, nil); err != nil {
 		return
 	}
-	if resp.Status != 200 {
-		err = fmt.Errorf("%v", resp.Error)
-		return
-	}
 	if err = json.Unmarshal(resp.Data, &storage); err != nil {
 		return
 	}
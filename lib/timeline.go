@@ -0,0 +1,198 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	TARGET_NODE    = "node"
+	TARGET_CLUSTER = "cluster"
+
+	TIMELINE_TIME_FORMAT = "2006-01-02 15:04:05.000"
+)
+
+type TimelineConfig struct {
+	JSONFile string
+	TextFile string
+}
+
+// TimelineEvent is a notification as observed during a run, tagged with
+// the phase of the test and the node or cluster it was raised for.
+type TimelineEvent struct {
+	Time     time.Time `json:"time"`
+	Observed time.Time `json:"observed"`
+	Phase    string    `json:"phase"`
+	TargetId uint64    `json:"targetId"`
+	Node     string    `json:"node,omitempty"`
+	Cluster  string    `json:"cluster,omitempty"`
+	Level    string    `json:"level"`
+	Message  string    `json:"message"`
+}
+
+type timelineTarget struct {
+	kind string
+	name string
+}
+
+// Timeline records every notification passed on by a hub, writing each
+// as a line of JSON and as a line of the human readable timeline, in
+// which phase changes are marked.
+type Timeline struct {
+	sub  *Subscription
+	p    *PccClient
+	done chan struct{}
+
+	mu      sync.Mutex
+	phase   string
+	targets map[uint64]timelineTarget
+	events  int
+	files   []*os.File
+	json    *bufio.Writer
+	text    *bufio.Writer
+}
+
+// StartTimeline starts recording the notifications of h, in
+// notifications.jsonl and notifications.txt by default.
+func StartTimeline(h *NotificationHub, config TimelineConfig) (
+	t *Timeline, err error) {
+
+	if config.JSONFile == "" {
+		config.JSONFile = "notifications.jsonl"
+	}
+	if config.TextFile == "" {
+		config.TextFile = "notifications.txt"
+	}
+
+	t = &Timeline{
+		p:       h.p,
+		done:    make(chan struct{}),
+		targets: make(map[uint64]timelineTarget),
+	}
+	for _, name := range []string{config.JSONFile, config.TextFile} {
+		var f *os.File
+
+		if f, err = os.Create(name); err != nil {
+			t.closeFiles()
+			t = nil
+			return
+		}
+		t.files = append(t.files, f)
+	}
+	t.json = bufio.NewWriter(t.files[0])
+	t.text = bufio.NewWriter(t.files[1])
+	t.sub = h.Subscribe()
+	go t.run()
+	return
+}
+
+// SetPhase tags the notifications observed from now on with name.
+func (t *Timeline) SetPhase(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if name == t.phase {
+		return
+	}
+	t.phase = name
+	fmt.Fprintf(t.text, "%v === %v\n",
+		time.Now().Format(TIMELINE_TIME_FORMAT), name)
+	t.text.Flush()
+}
+
+// Tag names the node or cluster, as kind says, of id in the
+// notifications observed from now on.
+func (t *Timeline) Tag(id uint64, kind string, name string) {
+	if t == nil || id == 0 {
+		return
+	}
+	t.mu.Lock()
+	t.targets[id] = timelineTarget{kind: kind, name: name}
+	t.mu.Unlock()
+}
+
+// Events returns how many notifications were recorded.
+func (t *Timeline) Events() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.events
+}
+
+// Close stops recording and closes the files.
+func (t *Timeline) Close() {
+	if t == nil {
+		return
+	}
+	t.sub.Close()
+	<-t.done
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.json.Flush()
+	t.text.Flush()
+	t.closeFiles()
+}
+
+func (t *Timeline) closeFiles() {
+	for _, f := range t.files {
+		f.Close()
+	}
+	t.files = nil
+}
+
+func (t *Timeline) run() {
+	defer close(t.done)
+	for {
+		n, err := t.sub.Next(t.p.Context(), nil)
+		if err != nil {
+			return
+		}
+		t.record(n)
+	}
+}
+
+func (t *Timeline) record(n Notification) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := TimelineEvent{
+		Time:     ConvertFromMillis(n.CreatedAt),
+		Observed: time.Now(),
+		Phase:    t.phase,
+		TargetId: n.TargetId,
+		Level:    n.Level,
+		Message:  n.Message,
+	}
+	target := fmt.Sprint(n.TargetId)
+	if tt, ok := t.targets[n.TargetId]; ok {
+		if tt.kind == TARGET_CLUSTER {
+			e.Cluster = tt.name
+		} else {
+			e.Node = tt.name
+		}
+		target = fmt.Sprintf("%v %v", tt.kind, tt.name)
+	}
+	t.events++
+
+	if b, err := json.Marshal(&e); err == nil {
+		t.json.Write(b)
+		t.json.WriteString("\n")
+		t.json.Flush()
+	}
+	fmt.Fprintf(t.text, "%v [%v] %-7v %-24v %v\n",
+		e.Time.Format(TIMELINE_TIME_FORMAT), e.Phase, e.Level, target,
+		e.Message)
+	t.text.Flush()
+}
@@ -0,0 +1,141 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// TLSConfig says how a PccClient checks the certificate of PCC.  By
+// default it must be signed by a CA of the system.  CAFile adds the PEM
+// bundle of the CAs to trust instead, Fingerprint pins the SHA-256 of
+// the certificate, e.g. "ab:cd:..." as printed by openssl, and Insecure
+// skips any check.  ServerName overrides the name checked against the
+// certificate, PccIp by default.  CertFile and KeyFile hold the PEM
+// client certificate to present, if any.
+type TLSConfig struct {
+	CAFile      string
+	Fingerprint string
+	Insecure    bool
+	ServerName  string
+	CertFile    string
+	KeyFile     string
+}
+
+func newTLSConfig(config TLSConfig) (t *tls.Config, err error) {
+	t = &tls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.Insecure,
+	}
+
+	if config.CAFile != "" {
+		var pem []byte
+
+		if pem, err = ioutil.ReadFile(config.CAFile); err != nil {
+			return
+		}
+		t.RootCAs = x509.NewCertPool()
+		if !t.RootCAs.AppendCertsFromPEM(pem) {
+			err = fmt.Errorf("no certificate found in %v",
+				config.CAFile)
+			return
+		}
+	}
+
+	if config.Fingerprint != "" {
+		var pin []byte
+
+		pin, err = hex.DecodeString(strings.Replace(config.Fingerprint,
+			":", "", -1))
+		if err != nil || len(pin) != sha256.Size {
+			err = fmt.Errorf("invalid SHA-256 fingerprint %v",
+				config.Fingerprint)
+			return
+		}
+		// the pin replaces the chain of trust, so self-signed
+		// appliances can be checked too
+		t.InsecureSkipVerify = true
+		t.VerifyPeerCertificate = func(certs [][]byte,
+			chains [][]*x509.Certificate) error {
+
+			if len(certs) == 0 {
+				return fmt.Errorf("no server certificate")
+			}
+			sum := sha256.Sum256(certs[0])
+			if !bytes.Equal(sum[:], pin) {
+				return fmt.Errorf("server certificate "+
+					"fingerprint %v doesn't match %v",
+					Fingerprint(certs[0]),
+					config.Fingerprint)
+			}
+			return nil
+		}
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		var cert tls.Certificate
+
+		cert, err = tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return
+		}
+		t.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// Fingerprint returns the SHA-256 of a DER certificate in the format
+// expected by TLSConfig.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	hexSum := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var pairs []string
+	for i := 0; i < len(hexSum); i += 2 {
+		pairs = append(pairs, hexSum[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
+
+// ServerCertificate connects to PCC and returns the certificate it
+// serves, after checking it as the TLSConfig of p says.  It is meant to
+// verify that PCC serves a valid certificate, e.g. after a certificate
+// upload.
+func (p *PccClient) ServerCertificate() (cert *x509.Certificate,
+	err error) {
+
+	var conn *tls.Conn
+
+	if p.baseURL.Scheme != "https" {
+		err = fmt.Errorf("%v is not served over TLS", p.baseURL)
+		return
+	}
+	addr := p.baseURL.Host
+	if p.baseURL.Port() == "" {
+		addr = net.JoinHostPort(p.pccIp, "443")
+	}
+	dialer := &net.Dialer{Timeout: p.dialTimeout}
+	conn, err = tls.DialWithDialer(dialer, "tcp", addr,
+		p.tlsConfig.Clone())
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		err = fmt.Errorf("%v served no certificate", p.pccIp)
+		return
+	}
+	cert = certs[0]
+	return
+}
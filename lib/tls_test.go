@@ -0,0 +1,197 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCert writes a self-signed certificate named cn, and its key, as PEM
+// files in dir and returns them.
+func newCert(t *testing.T, dir string, cn string) (certFile string,
+	keyFile string) {
+
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign |
+			x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth,
+			x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, cn+".crt")
+	keyFile = filepath.Join(dir, cn+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return
+}
+
+func writePEM(t *testing.T, file string, kind string, der []byte) {
+	t.Helper()
+	b := pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTLSServer serves h over TLS, with config if not nil, quiet about
+// the handshakes failed on purpose.
+func newTLSServer(h http.HandlerFunc, config *tls.Config) *httptest.Server {
+	s := httptest.NewUnstartedServer(h)
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.TLS = config
+	s.StartTLS()
+	return s
+}
+
+// getTLS gets url with the TLS config made of config.
+func getTLS(url string, config TLSConfig) (err error) {
+	t, err := newTLSConfig(config)
+	if err != nil {
+		return
+	}
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: t,
+	}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	return
+}
+
+func TestTLSServerCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newTLSServer(func(w http.ResponseWriter, r *http.Request) {},
+		nil)
+	defer s.Close()
+	ca := filepath.Join(dir, "pcc.crt")
+	writePEM(t, ca, "CERTIFICATE", s.Certificate().Raw)
+	otherCA, _ := newCert(t, dir, "other")
+	pin := Fingerprint(s.Certificate().Raw)
+	otherPin := strings.Repeat("AB:", 31) + "AB"
+
+	for _, tc := range []struct {
+		name   string
+		config TLSConfig
+		fail   string
+	}{
+		{"system CAs", TLSConfig{}, "certificate"},
+		{"insecure", TLSConfig{Insecure: true}, ""},
+		{"pin", TLSConfig{Fingerprint: pin}, ""},
+		{"lowercase pin", TLSConfig{
+			Fingerprint: strings.ToLower(pin)}, ""},
+		{"other pin", TLSConfig{Fingerprint: otherPin},
+			"doesn't match"},
+		{"CA", TLSConfig{CAFile: ca}, ""},
+		{"other CA", TLSConfig{CAFile: otherCA},
+			"certificate signed by unknown authority"},
+		{"CA and other pin", TLSConfig{CAFile: ca,
+			Fingerprint: otherPin}, "doesn't match"},
+	} {
+		err := getTLS(s.URL, tc.config)
+		switch {
+		case tc.fail == "" && err != nil:
+			t.Errorf("%v: %v", tc.name, err)
+		case tc.fail != "" && err == nil:
+			t.Errorf("%v: no error", tc.name)
+		case tc.fail != "" && !strings.Contains(err.Error(), tc.fail):
+			t.Errorf("%v: %v, want %q", tc.name, err, tc.fail)
+		}
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, key := newCert(t, dir, "blackbox")
+	var presented string
+	s := newTLSServer(func(w http.ResponseWriter, r *http.Request) {
+		presented = r.TLS.PeerCertificates[0].Subject.CommonName
+	}, &tls.Config{ClientAuth: tls.RequireAnyClientCert})
+	defer s.Close()
+
+	config := TLSConfig{Insecure: true, CertFile: cert, KeyFile: key}
+	if err = getTLS(s.URL, config); err != nil {
+		t.Fatal(err)
+	}
+	if presented != "blackbox" {
+		t.Errorf("presented %q, want blackbox", presented)
+	}
+	if err = getTLS(s.URL, TLSConfig{Insecure: true}); err == nil {
+		t.Error("no error without a client certificate")
+	}
+	config.KeyFile = filepath.Join(dir, "missing.key")
+	if _, err = newTLSConfig(config); err == nil {
+		t.Error("no error for a missing key")
+	}
+}
+
+func TestTLSBadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	empty := filepath.Join(dir, "empty.crt")
+	if err = ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range []TLSConfig{
+		{Fingerprint: "AB:CD"},
+		{Fingerprint: strings.Repeat("ZZ", 32)},
+		{CAFile: empty},
+		{CAFile: filepath.Join(dir, "missing.crt")},
+	} {
+		if _, err = newTLSConfig(config); err == nil {
+			t.Errorf("%+v: no error", config)
+		}
+	}
+}
@@ -5,3 +5,7 @@ import "time"
 func ConvertToMillis(startTime time.Time) uint64 {
 	return uint64(startTime.UnixNano()) / uint64(time.Millisecond)
 }
+
+func ConvertFromMillis(millis uint64) time.Time {
+	return time.Unix(0, int64(millis)*int64(time.Millisecond))
+}
@@ -1,17 +1,16 @@
 package pcc
 
 import (
-	"time"
 	"fmt"
-	"strings"
+	"time"
 )
 
 const (
-	FREQUENCY            = 10
+	FREQUENCY = 10
 )
 
 type Status struct {
-	Msg string
+	Msg     string
 	IsError bool
 }
 
@@ -22,68 +21,43 @@ type EventsToCheck map[string]bool
 type Verifier interface {
 	GetTimeout() time.Duration
 	GetEventsToCheck() EventsToCheck
-	GetBreakLoopChan() chan bool
 }
 
-func (p *PccClient) Verify(startTime time.Time, v Verifier) (s Status){
-	done := make(chan Status)
-	go p.syncCheckGenericInstallation(0, v, startTime, done)
-	s = <- done
-	go func() {
-		if v.GetBreakLoopChan() != nil {
-			v.GetBreakLoopChan() <- true
-		}
-	}()
-
-	return
+// FailureVerifier is a Verifier that knows which of its terminating
+// events report a failure, Verify then returning an error status.
+type FailureVerifier interface {
+	Verifier
+	GetFailures() []string
 }
 
-// Synchronize checking for installation
-func (p *PccClient) syncCheckGenericInstallation(id uint64, v Verifier, from time.Time, found chan Status) {
-	s := Status{}
-	timeout := v.GetTimeout() * time.Second
-	for time.Since(from) < timeout {
-		select {
-		case <- v.GetBreakLoopChan():
-			return
-		default:
-			var (
-				events    []Notification
-				err       error
-			)
-			events, err = p.GetNotifications()
-			if err != nil {
-				s.Msg = fmt.Sprintf("failed to get notifications ERROR: %v", err)
-				s.IsError = true
-				found <- s
-			}else {
-				eventsLoop:
-				for i := 0; i < len(events); i++ {
-					if events[i].CreatedAt < ConvertToMillis(from) {
-						continue
-					}
-					eventsToCheck := v.GetEventsToCheck()
-					for msg, terminate := range eventsToCheck {
-						eventMsg := events[i].Message
-						if strings.Contains(eventMsg, msg) {
-							if terminate {
-								s.Msg = fmt.Sprintf("\"%v\" notification found in events", eventMsg)
-								s.IsError = false
-								found <- s
-								break eventsLoop
-							}else {
-								fmt.Println("notification: ", eventMsg)
-								delete(eventsToCheck, msg)
-							}
-						}
-					}
-				}
-			}
+// Verify waits for one of the terminating events of v, logging the
+// others as they are raised.
+func (p *PccClient) Verify(startTime time.Time, v Verifier) (s Status) {
+	w := EventWaiter{Timeout: v.GetTimeout() * time.Second}
+	failures := make(map[string]bool)
+	if fv, ok := v.(FailureVerifier); ok {
+		w.Failure = fv.GetFailures()
+		for _, msg := range w.Failure {
+			failures[msg] = true
+		}
+	}
+	for msg, terminate := range v.GetEventsToCheck() {
+		if failures[msg] {
+			continue
+		}
+		if terminate {
+			w.Success = append(w.Success, msg)
+		} else {
+			w.Progress = append(w.Progress, msg)
 		}
-		time.Sleep(FREQUENCY * time.Second)
 	}
 
-	s.Msg = fmt.Sprint("timeout exceeded")
-	s.IsError = true
-	found <- s
+	n, err := p.WaitForEvent(startTime, w)
+	if err != nil {
+		s.Msg = err.Error()
+		s.IsError = true
+		return
+	}
+	s.Msg = fmt.Sprintf("\"%v\" notification found in events", n.Message)
+	return
 }
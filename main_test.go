@@ -9,9 +9,9 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"testing"
-	"time"
 
 	pcc "github.com/platinasystems/pcc-blackbox/lib"
 	"github.com/platinasystems/test"
@@ -26,6 +26,8 @@ var SecurityKeys = make(map[string]*pcc.SecurityKey)
 var NodebyHostIP = make(map[string]uint64)
 
 var dockerStats *pcc.DockerStats
+var timeline *pcc.Timeline
+var metrics *pcc.Metrics
 
 func TestMain(m *testing.M) {
 	var (
@@ -52,15 +54,43 @@ func TestMain(m *testing.M) {
 			envFile, err.Error()))
 	}
 
+	if Env.FakePcc {
+		if err = startFakePcc(); err != nil {
+			panic(fmt.Errorf("Fake PCC error: %v\n", err))
+		}
+		defer stopFakePcc()
+	}
+
 	credential := pcc.Credential{
 		UserName: "admin",
 		Password: "admin",
 	}
-	Pcc, err = pcc.Authenticate(Env.PccIp, credential)
+	Pcc, err = pcc.AuthenticateWithConfig(Env.PccIp, credential,
+		Env.PccClient)
 	if err != nil {
 		panic(fmt.Errorf("Authentication error: %v\n", err))
 	}
+	Pcc.OnTokenRefresh(func(err error) {
+		if err != nil {
+			fmt.Printf("PCC token refresh failed: %v\n", err)
+			return
+		}
+		fmt.Println("PCC token refreshed")
+	})
 
+	// the containers of the PCC host, not those of this machine
+	ds := &Env.DockerStats
+	switch ds.Source {
+	case pcc.DOCKER_SOURCE_API:
+		if ds.Host == "" {
+			ds.Host = "tcp://" + net.JoinHostPort(Pcc.Host(),
+				fmt.Sprint(pcc.DOCKER_API_PORT))
+		}
+	case pcc.DOCKER_SOURCE_SSH:
+		if ds.SSH.Host == "" {
+			ds.SSH.Host = Pcc.Host()
+		}
+	}
 	dockerStats = pcc.InitDockerStats(Env.DockerStats)
 	flag.Parse()
 	if *test.DryRun {
@@ -68,9 +98,44 @@ func TestMain(m *testing.M) {
 		return
 	}
 
+	if metrics, err = pcc.StartMetrics(Env.Metrics); err != nil {
+		panic(fmt.Errorf("Metrics error: %v\n", err))
+	}
+	Pcc.ExportMetrics(metrics)
+	dockerStats.ExportMetrics(metrics)
+
+	// one stream, or poller, for all the notification waits
+	hub := Pcc.StartNotificationHub(0)
+	if timeline, err = pcc.StartTimeline(hub, Env.Timeline); err != nil {
+		panic(fmt.Errorf("Notification timeline error: %v\n", err))
+	}
+
 	ecode = m.Run()
 
+	timeline.Close()
+	hub.Close()
+	if err = Pcc.Close(); err != nil {
+		fmt.Printf("Failed to close the PCC cassette: %v\n", err)
+	}
 	dockerStats.Stop()
+	if err = metrics.Close(); err != nil {
+		fmt.Printf("Failed to write metrics: %v\n", err)
+	}
+	fmt.Println("\nContainer usage per phase:")
+	pcc.WriteDockerSummary(os.Stdout, dockerStats.Summary())
+	violations, err := dockerStats.CheckAndSaveBaseline()
+	if err != nil {
+		fmt.Printf("Failed to check container usage: %v\n", err)
+	}
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+	if violations.Failed() && ecode == 0 {
+		fmt.Println("Container usage over budget")
+		ecode = 1
+	}
+	fmt.Printf("PCC requests retried: %v\n", Pcc.Retries())
+	fmt.Printf("PCC notifications recorded: %v\n", timeline.Events())
 	fmt.Println("\n\nTEST COMPLETED")
 }
 
@@ -80,161 +145,90 @@ var timeFormat = "Mon Jan 2 15:04:05 2006"
 // TestNodes can be used to
 // automatically config a cluser
 func TestNodes(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n",
-		count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "getSecKeys", getSecKeys)
-		mayRun(t, "updateSecurityKey", updateSecurityKey_MaaS)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "installMAAS", updateNodes_installMAAS)
-		mayRun(t, "configServerInterfaces", configServerInterfaces)
-		mayRun(t, "updateBmcInfo", updateBmcInfo)
-	})
+	runPlan(t, plans["nodes"])
 }
 
 func TestMaaS(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "getSecKeys", getSecKeys)
-		mayRun(t, "updateSecurityKey", updateSecurityKey_MaaS)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "configServerInterfaces", configServerInterfaces)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "installMAAS", updateNodes_installMAAS)
-		mayRun(t, "reimageAllBrownNodes", reimageAllBrownNodes)
-	})
+	runPlan(t, plans["maas"])
 }
 
 func TestTenantMaaS(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "getSecKeys", getSecKeys)
-		mayRun(t, "updateSecurityKey", updateSecurityKey_MaaS)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "configServerInterfaces", configServerInterfaces)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "installMAAS", updateNodes_installMAAS)
-		mayRun(t, "addTenant", addTenant)
-		mayRun(t, "addSite", addSite)
-		mayRun(t, "reimageTenantAllBrownNodes", reimageAllBrownNodes)
-	})
+	runPlan(t, plans["tenantMaas"])
 }
 
 func TestK8s(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "configServerInterfaces", configServerInterfaces)
-		mayRun(t, "CreateK8sCluster", createK8sCluster)
-	})
+	runPlan(t, plans["k8s"])
 }
 
 func TestDeleteK8s(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "deleteK8sCluster", deleteK8sCluster)
-	})
+	runPlan(t, plans["deleteK8s"])
 }
 
 func TestCeph(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format("Mon Jan 2 15:04:05 2006"))
-	mayRun(t, "ceph", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "configNetworkIntefaces", configNetworkInterfaces)
-		mayRun(t, "testCeph", testCeph)
-	})
+	runPlan(t, plans["ceph"])
 }
 
 func TestPortus(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "portus", func(t *testing.T) {
-		mayRun(t, "getNodesList", getNodes)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "uploadSecurityAuthProfileCertificate", UploadSecurityAuthProfileCert)
-		mayRun(t, "addProfile", AddAuthenticationProfile)
-		mayRun(t, "uploadSecurityPortusKey", UploadSecurityPortusKey)
-		mayRun(t, "uploadSecurityPortusCertificate", UploadSecurityPortusCert)
-		mayRun(t, "installPortus", AddPortus)
-		mayRun(t, "checkPortusInstallation", CheckPortusInstallation)
-	})
+	runPlan(t, plans["portus"])
 }
 
 func TestHardwareInventory(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "hardwareinventory", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "installMAAS", updateNodes_installMAAS)
-		mayRun(t, "testHardwareInventory", testHardwareInventory)
-	})
+	runPlan(t, plans["hardwareInventory"])
 }
 
 func TestFull(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "getNodeList", getNodes)
-		mayRun(t, "getSecKeys", getSecKeys)
-		mayRun(t, "updateSecurityKey", updateSecurityKey_MaaS)
-		mayRun(t, "addInvaders", addClusterHeads)
-		mayRun(t, "addBrownfieldNodes", addBrownfieldServers)
-		mayRun(t, "installLLDP", updateNodes_installLLDP)
-		mayRun(t, "installMAAS", updateNodes_installMAAS)
-		mayRun(t, "configServerInterfaces", configServerInterfaces)
-		mayRun(t, "reimageAllBrownNodes", reimageAllBrownNodes)
-		mayRun(t, "addTenant", addTenant)
-		mayRun(t, "addSite", addSite)
-		mayRun(t, "reimageTenantAllBrownNodes", reimageAllBrownNodes)
-		mayRun(t, "CreateK8sCluster", createK8sCluster)
-	})
+	runPlan(t, plans["full"])
 }
 
 func TestClean(t *testing.T) {
-	count++
-	fmt.Printf("Environment:\n%v\n", Env)
-	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
-	mayRun(t, "nodes", func(t *testing.T) {
-		mayRun(t, "getAvailableNodes", getAvailableNodes)
-		mayRun(t, "deleteK8sCluster", deleteK8sCluster)
-		mayRun(t, "delAllPortus", delAllPortus)
-		mayRun(t, "delAllNodes", delAllNodes)
-		mayRun(t, "delAllUsers", delAllUsers)
-		mayRun(t, "delAllTenants", delAllTenants)
-		mayRun(t, "delAllKeys", delAllKeys)
-		mayRun(t, "delAllProfiles", delAllProfiles)
-		mayRun(t, "delAllCerts", delAllCerts)
-	})
+	runPlan(t, plans["clean"])
+}
+
+// TestPlan runs the plan chosen with -plan.
+func TestPlan(t *testing.T) {
+	if *planFlag == "" {
+		t.Skip("no -plan")
+	}
+	plan, err := loadPlan(*planFlag)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	runPlan(t, plan)
+}
+
+// suites are those TestSoak can repeat.
+var suites = map[string]func(*testing.T){
+	"TestNodes":             TestNodes,
+	"TestMaaS":              TestMaaS,
+	"TestTenantMaaS":        TestTenantMaaS,
+	"TestK8s":               TestK8s,
+	"TestDeleteK8s":         TestDeleteK8s,
+	"TestCeph":              TestCeph,
+	"TestPortus":            TestPortus,
+	"TestHardwareInventory": TestHardwareInventory,
+	"TestFull":              TestFull,
+	"TestClean":             TestClean,
+}
+
+// TestSoak repeats the suite, or plan, named by -soak and flags the
+// containers whose footprint grows at each iteration.
+func TestSoak(t *testing.T) {
+	if *soakSuite == "" {
+		t.Skip("no -soak suite")
+	}
+	suite, found := suites[*soakSuite]
+	if !found {
+		plan, err := loadPlan(*soakSuite)
+		if err != nil {
+			t.Fatalf("unknown suite %v: %v", *soakSuite, err)
+			return
+		}
+		suite = func(t *testing.T) {
+			runPlan(t, plan)
+		}
+	}
+	runSoak(t, suite)
 }
 
 func TestGen(t *testing.T) {
@@ -245,15 +239,39 @@ func TestGen(t *testing.T) {
 }
 
 func mayRun(t *testing.T, name string, f func(*testing.T)) bool {
-	dockerStats.ChangePhase(name)
 	var ret bool
 	t.Helper()
 	if !t.Failed() {
-		ret = t.Run(name, f)
+		ret = runPhase(t, name, f)
+	} else {
+		dockerStats.ChangePhase(name)
+		timeline.SetPhase(name)
 	}
 	return ret
 }
 
+// runPhase runs f as the subtest name, a phase of the container stats,
+// the notification timeline and the metrics.  The messages of lib go to
+// the log of the subtest, with the phase.
+func runPhase(t *testing.T, name string, f func(*testing.T)) bool {
+	dockerStats.ChangePhase(name)
+	timeline.SetPhase(name)
+	// checked when Pcc was authenticated
+	level, _ := pcc.ParseLogLevel(Env.PccClient.LogLevel)
+	start := Pcc.Clock().Now()
+	ret := t.Run(name, func(t *testing.T) {
+		defer func(p *pcc.PccClient) { Pcc = p }(Pcc)
+		Pcc = Pcc.WithLogger(pcc.FuncLogger{
+			Level: level,
+			Logf:  t.Logf,
+		}).WithFields(pcc.Fields{"phase": name})
+		f(t)
+	})
+	metrics.ObservePhase(name, ret, Pcc.Since(start))
+	metrics.Flush()
+	return ret
+}
+
 func uutInfo() {
 	fmt.Println("---")
 	defer fmt.Println("...")
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os/exec"
-	"strings"
 	"testing"
 	"time"
 
@@ -43,7 +42,7 @@ func checkNodeAdd(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	from := time.Now()
+	from := Pcc.Clock().Now()
 	err := verifyAddNode(from, "nodeAdd")
 	if err != nil {
 		fmt.Println("Node additon failed..ERROR:", err)
@@ -125,33 +124,17 @@ func powerCycleNode(t *testing.T) {
 }
 
 func verifyAddNode(from time.Time, action string) (err error) {
-	done := make(chan status)
-	breakLoop := make([]chan bool, 2)
-
-	if action == "nodeAdd" {
-		go func() {
-			breakLoop[0] = make(chan bool)
-			syncCheckGenericInstallation(0, PXEBOOT_TIMEOUT, PXEBOOT_NODE_ADD_NOTIFICATION, from, done, breakLoop[0])
-		}()
-		go func() {
-			breakLoop[1] = make(chan bool)
-			syncCheckGenericInstallation(0, PXEBOOT_TIMEOUT, PXEBOOT_NODE_ADD_FAILED_NOTIFICATION, from, done, breakLoop[1])
-		}()
+	if action != "nodeAdd" {
+		return
 	}
-	s := <-done
-	go func() {
-		for i := 0; i < 2; i++ {
-			breakLoop[i] <- true
-		}
-	}()
-	if !s.isError {
-		if strings.Contains(s.msg, PXEBOOT_NODE_ADD_NOTIFICATION) {
-			fmt.Println("Node is added succesfully..\n", s.msg)
-		} else if strings.Contains(s.msg, PXEBOOT_NODE_ADD_FAILED_NOTIFICATION) {
-			err = fmt.Errorf("%v", s.msg)
-		}
-	} else {
-		err = fmt.Errorf("%v", s.msg)
+	// the id of the node isn't known until it is added
+	w, err := Pcc.Catalog().Waiter(pcc.PXEBOOT_NODE_ADD_EVENT, nil)
+	if err != nil {
+		return
+	}
+	n, err := Pcc.WaitForEvent(from, w)
+	if err == nil {
+		fmt.Println("Node is added succesfully..\n", n.Message)
 	}
 	return
 }
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var planFlag = flag.String("plan", "",
+	"test plan run by TestPlan: one of plans, e.g. full, or a YAML or "+
+		"JSON file")
+
+// testPlan is a suite: the steps run, in order, as subtests of Name.
+type testPlan struct {
+	Name  string     `yaml:"name"`
+	Steps []planStep `yaml:"steps"`
+}
+
+// planStep runs the step of steps named Step, as a subtest named Name,
+// Step by default.  Params are read by the step with stepParam.  Unless
+// ContinueOnFailure, a failed step ends the plan.
+type planStep struct {
+	Step              string            `yaml:"step"`
+	Name              string            `yaml:"name,omitempty"`
+	Params            map[string]string `yaml:"params,omitempty"`
+	ContinueOnFailure bool              `yaml:"continueOnFailure,omitempty"`
+}
+
+// stepDef is a step of the registry: it runs run, which needs the
+// shared state named by consumes and sets that named by produces.
+type stepDef struct {
+	run      func(*testing.T)
+	consumes []string
+	produces []string
+}
+
+// steps are those a plan can run, by name.
+var steps = map[string]stepDef{
+	"getNodeList": {
+		run:      getNodes,
+		produces: []string{STATE_NODES},
+	},
+	"getAvailableNodes": {
+		run:      getAvailableNodes,
+		produces: []string{STATE_NODES},
+	},
+	"getSecKeys": {
+		run:      getSecKeys,
+		produces: []string{STATE_SECURITY_KEYS},
+	},
+	"updateSecurityKey": {
+		run: updateSecurityKey_MaaS,
+	},
+	"addInvaders": {
+		run:      addClusterHeads,
+		consumes: []string{STATE_NODES},
+		produces: []string{STATE_NODES},
+	},
+	"addBrownfieldNodes": {
+		run:      addBrownfieldServers,
+		consumes: []string{STATE_NODES},
+		produces: []string{STATE_NODES},
+	},
+	"installLLDP": {
+		run:      updateNodes_installLLDP,
+		consumes: []string{STATE_NODES},
+	},
+	"installMAAS": {
+		run:      updateNodes_installMAAS,
+		consumes: []string{STATE_NODES},
+	},
+	"configServerInterfaces": {
+		run:      configServerInterfaces,
+		consumes: []string{STATE_NODES},
+	},
+	"configNetworkInterfaces": {
+		run:      configNetworkInterfaces,
+		consumes: []string{STATE_NODES},
+	},
+	"updateBmcInfo": {
+		run:      updateBmcInfo,
+		consumes: []string{STATE_NODES},
+	},
+	"reimageAllBrownNodes": {
+		run:      reimageAllBrownNodes,
+		consumes: []string{STATE_NODES},
+	},
+	"addTenant": {
+		run:      addTenant,
+		consumes: []string{STATE_NODES},
+	},
+	"addSite": {
+		run: addSite,
+	},
+	"CreateK8sCluster": {
+		run:      createK8sCluster,
+		consumes: []string{STATE_NODES},
+	},
+	"deleteK8sCluster": {
+		run: deleteK8sCluster,
+	},
+	"testCeph": {
+		run:      testCeph,
+		consumes: []string{STATE_NODES},
+	},
+	"uploadSecurityAuthProfileCertificate": {
+		run: UploadSecurityAuthProfileCert,
+	},
+	"addProfile": {
+		run:      AddAuthenticationProfile,
+		produces: []string{STATE_AUTH_PROFILE},
+	},
+	"uploadSecurityPortusKey": {
+		run: UploadSecurityPortusKey,
+	},
+	"uploadSecurityPortusCertificate": {
+		run: UploadSecurityPortusCert,
+	},
+	"installPortus": {
+		run:      AddPortus,
+		consumes: []string{STATE_NODES},
+		produces: []string{STATE_PORTUS_NODES},
+	},
+	"checkPortusInstallation": {
+		run:      CheckPortusInstallation,
+		consumes: []string{STATE_NODES, STATE_PORTUS_NODES},
+	},
+	"testHardwareInventory": {
+		run:      testHardwareInventory,
+		produces: []string{STATE_PXE_BOOT_NODE},
+	},
+	"delAllPortus": {
+		run: delAllPortus,
+	},
+	"delAllNodes": {
+		run:      delAllNodes,
+		consumes: []string{STATE_NODES},
+	},
+	"delAllUsers": {
+		run: delAllUsers,
+	},
+	"delAllTenants": {
+		run: delAllTenants,
+	},
+	"delAllKeys": {
+		run: delAllKeys,
+	},
+	"delAllProfiles": {
+		run: delAllProfiles,
+	},
+	"delAllCerts": {
+		run: delAllCerts,
+	},
+}
+
+// plans are the plans of the Test* suites, by name.
+var plans = map[string]testPlan{
+	"nodes": {Name: "nodes", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "getSecKeys"},
+		{Step: "updateSecurityKey"},
+		{Step: "addInvaders"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "installLLDP"},
+		{Step: "installMAAS"},
+		{Step: "configServerInterfaces"},
+		{Step: "updateBmcInfo"},
+	}},
+	"maas": {Name: "nodes", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "getSecKeys"},
+		{Step: "updateSecurityKey"},
+		{Step: "addInvaders"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "configServerInterfaces"},
+		{Step: "installLLDP"},
+		{Step: "installMAAS"},
+		{Step: "reimageAllBrownNodes"},
+	}},
+	"tenantMaas": {Name: "nodes", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "getSecKeys"},
+		{Step: "updateSecurityKey"},
+		{Step: "addInvaders"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "configServerInterfaces"},
+		{Step: "installLLDP"},
+		{Step: "installMAAS"},
+		{Step: "addTenant"},
+		{Step: "addSite"},
+		{Step: "reimageAllBrownNodes",
+			Name: "reimageTenantAllBrownNodes"},
+	}},
+	"k8s": {Name: "nodes", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "addInvaders"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "installLLDP"},
+		{Step: "configServerInterfaces"},
+		{Step: "CreateK8sCluster"},
+	}},
+	"deleteK8s": {Name: "nodes", Steps: []planStep{
+		{Step: "deleteK8sCluster"},
+	}},
+	"ceph": {Name: "ceph", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "addInvaders"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "installLLDP"},
+		{Step: "configNetworkInterfaces",
+			Name: "configNetworkIntefaces"},
+		{Step: "testCeph"},
+	}},
+	"portus": {Name: "portus", Steps: []planStep{
+		{Step: "getNodeList", Name: "getNodesList"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "uploadSecurityAuthProfileCertificate"},
+		{Step: "addProfile"},
+		{Step: "uploadSecurityPortusKey"},
+		{Step: "uploadSecurityPortusCertificate"},
+		{Step: "installPortus"},
+		{Step: "checkPortusInstallation"},
+	}},
+	"hardwareInventory": {Name: "hardwareinventory", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "addInvaders"},
+		{Step: "installLLDP"},
+		{Step: "installMAAS"},
+		{Step: "testHardwareInventory"},
+	}},
+	"full": {Name: "nodes", Steps: []planStep{
+		{Step: "getNodeList"},
+		{Step: "getSecKeys"},
+		{Step: "updateSecurityKey"},
+		{Step: "addInvaders"},
+		{Step: "addBrownfieldNodes"},
+		{Step: "installLLDP"},
+		{Step: "installMAAS"},
+		{Step: "configServerInterfaces"},
+		{Step: "reimageAllBrownNodes"},
+		{Step: "addTenant"},
+		{Step: "addSite"},
+		{Step: "reimageAllBrownNodes",
+			Name: "reimageTenantAllBrownNodes"},
+		{Step: "CreateK8sCluster"},
+	}},
+	"clean": {Name: "nodes", Steps: []planStep{
+		{Step: "getAvailableNodes"},
+		{Step: "deleteK8sCluster"},
+		{Step: "delAllPortus"},
+		{Step: "delAllNodes"},
+		{Step: "delAllUsers"},
+		{Step: "delAllTenants"},
+		{Step: "delAllKeys"},
+		{Step: "delAllProfiles"},
+		{Step: "delAllCerts"},
+	}},
+}
+
+// check tells whether the steps of plan are known, and the state each
+// needs set by a step before it.
+func (plan *testPlan) check() error {
+	if plan.Name == "" {
+		return fmt.Errorf("plan has no name")
+	}
+	produced := make(map[string]bool)
+	for i, s := range plan.Steps {
+		def, found := steps[s.Step]
+		if !found {
+			return fmt.Errorf("plan %v: step %v: unknown step %q",
+				plan.Name, i+1, s.Step)
+		}
+		for _, state := range def.consumes {
+			if !produced[state] {
+				return fmt.Errorf("plan %v: step %v: %v needs the %v "+
+					"of a step before it", plan.Name, i+1, s.Step,
+					state)
+			}
+		}
+		for _, state := range def.produces {
+			produced[state] = true
+		}
+	}
+	return nil
+}
+
+// loadPlan returns the plan named name, or read from the file name.
+func loadPlan(name string) (plan testPlan, err error) {
+	if p, found := plans[name]; found {
+		plan = p
+		return
+	}
+
+	var b []byte
+
+	if b, err = ioutil.ReadFile(name); err != nil {
+		var names []string
+		for n := range plans {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		err = fmt.Errorf("%v, and not a plan of %v", err,
+			strings.Join(names, ", "))
+		return
+	}
+	if filepath.Ext(name) == ".json" {
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&plan)
+	} else {
+		err = yaml.UnmarshalStrict(b, &plan)
+	}
+	if err != nil {
+		err = fmt.Errorf("%v: %v", name, err)
+		return
+	}
+	err = plan.check()
+	return
+}
+
+func (s *planStep) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Step
+}
+
+// stepParams are the Params of the step running.
+var stepParams map[string]string
+
+// stepParam returns the param name of the step running, def if not set.
+func stepParam(name string, def string) string {
+	if v, found := stepParams[name]; found {
+		return v
+	}
+	return def
+}
+
+// runPlan runs the steps of plan as subtests of a subtest named after it,
+// ending with the first failure of a step not to continue on failure.
+// The progress is saved in the -checkpoint, if set, after each step;
+// with -resume, the steps done in it are skipped.
+func runPlan(t *testing.T, plan testPlan) {
+	count++
+	fmt.Printf("Environment:\n%v\n", Env)
+	fmt.Printf("Iteration %v, %v\n", count, time.Now().Format(timeFormat))
+	if err := plan.check(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	cp, err := startCheckpoint(plan)
+	if err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+		return
+	}
+	mayRun(t, plan.Name, func(t *testing.T) {
+		for i, s := range plan.Steps {
+			if cp.done(i) {
+				t.Run(s.name(), func(t *testing.T) {
+					t.Skip("done before the checkpoint")
+				})
+				continue
+			}
+			def := steps[s.Step]
+			stepParams = s.Params
+			ok := runPhase(t, s.name(), def.run)
+			stepParams = nil
+			if err := cp.record(i, def.produces, ok); err != nil {
+				t.Errorf("Failed to save checkpoint: %v", err)
+			}
+			if !ok && !s.ContinueOnFailure {
+				return
+			}
+		}
+	})
+}
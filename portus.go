@@ -112,11 +112,11 @@ func checkPortus(t *testing.T) {
 	test.SkipIfDryRun(t)
 	assert := test.Assert{t}
 
-	from := time.Now()
+	from := Pcc.Clock().Now()
 
 	for id, node := range Nodes {
 		if idInSlice(node.Id, PortusSelectedNodeIds) {
-			check, err := checkGenericInstallation(id, PORTUS_TIMEOUT, PORTUS_NOTIFICATION, from)
+			check, err := checkInstallation(id, pcc.PORTUS_INSTALL_EVENT, from)
 			if err != nil {
 				assert.Fatalf("Portus installation has failed\n%v\n", err)
 			}
@@ -154,14 +154,15 @@ func delAllPortus(t *testing.T) {
 		}
 		// wait till deleted
 		done := false
-		timeout := time.After(10 * time.Minute)
-		tick := time.Tick(30 * time.Second)
+		timeout := Pcc.Clock().After(10 * time.Minute)
+		tick := Pcc.Clock().After(30 * time.Second)
 		for !done {
 			select {
 			case <-tick:
+				tick = Pcc.Clock().After(30 * time.Second)
 				_, err = Pcc.GetPortusNodeById(id)
 				if err != nil {
-					if err.Error() == "record not found" {
+					if pcc.IsNotFound(err) {
 						done = true
 						continue
 					}
@@ -76,7 +76,7 @@ func reimageAllBrown(t *testing.T) {
 	}
 
 	fmt.Println("Sleep for 8 minutes")
-	time.Sleep(8 * time.Minute)
+	Pcc.Sleep(8 * time.Minute)
 
 	for {
 		for i, id := range nodesList {
@@ -89,7 +89,7 @@ func reimageAllBrown(t *testing.T) {
 				fmt.Printf("Node %v has gone Ready\n", id)
 				nodesList = removeIndex(i, nodesList)
 				continue
-			} else if strings.Contains(status, "reimage failed") {
+			} else if strings.Contains(status, pcc.PROVISION_STATUS_REIMAGE_FAILED) {
 				fmt.Printf("Node %v has failed reimage\n", id)
 				nodesList = removeIndex(i, nodesList)
 				continue
@@ -100,7 +100,7 @@ func reimageAllBrown(t *testing.T) {
 			fmt.Printf("Brownfield re-image done\n")
 			return
 		}
-		time.Sleep(60 * time.Second)
+		Pcc.Sleep(60 * time.Second)
 	}
 }
 
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	pcc "github.com/platinasystems/pcc-blackbox/lib"
+)
+
+var (
+	soakSuite = flag.String("soak", "",
+		"suite or test plan to repeat in TestSoak, e.g. TestNodes")
+	soakIterations = flag.Uint("soak.iterations", 0,
+		"number of soak iterations")
+	soakDuration = flag.Duration("soak.duration", 0,
+		"run soak iterations for that long, without -soak.iterations")
+	soakGrowth = flag.Uint("soak.growth", pcc.DEFAULT_SOAK_MIN_GROWTH,
+		"growth in percent over the soak from which a steadily "+
+			"growing container footprint is a leak")
+)
+
+// runSoak runs suite -soak.iterations times, or for -soak.duration,
+// sampling the memory, processes and threads, open files and goroutines
+// of the containers at the end of each iteration, and fails for those that grow
+// at each one.
+func runSoak(t *testing.T, suite func(*testing.T)) {
+	if *soakIterations == 0 && *soakDuration == 0 {
+		t.Fatal("set -soak.iterations or -soak.duration")
+		return
+	}
+
+	soak := pcc.NewSoak()
+	soak.MinGrowth = uint16(*soakGrowth)
+	start := Pcc.Clock().Now()
+	for i := uint(1); ; i++ {
+		if *soakIterations != 0 && i > *soakIterations {
+			break
+		}
+		if *soakIterations == 0 && Pcc.Since(start) >= *soakDuration {
+			break
+		}
+		if !t.Run(fmt.Sprintf("iteration%v", i), suite) {
+			break
+		}
+		samples, err := dockerStats.Sample()
+		if err != nil {
+			t.Errorf("Failed to sample containers: %v", err)
+			continue
+		}
+		soak.Record(samples)
+		fmt.Printf("Soak iteration %v done after %v\n", i,
+			Pcc.Since(start).Round(time.Second))
+	}
+
+	fmt.Printf("\nContainer footprint over %v soak iterations:\n",
+		soak.Iterations())
+	pcc.WriteSoakReport(os.Stdout, soak.Trends())
+	for _, l := range soak.Leaks() {
+		t.Errorf("%v %v grew at each of %v iterations, by %.1f%%",
+			l.Container, l.Metric, len(l.Values), l.Growth)
+	}
+}
@@ -5,9 +5,13 @@ import pcc "github.com/platinasystems/pcc-blackbox/lib"
 type testEnv struct {
 	Env                   string
 	PccIp                 string
+	FakePcc               bool
+	PccClient             pcc.PccClientConfig
 	Invaders              []invader
 	Servers               []server
 	DockerStats           pcc.DockerStatsConfig
+	Timeline              pcc.TimelineConfig
+	Metrics               pcc.MetricsConfig
 	AuthenticationProfile pcc.AuthenticationProfile
 	PortusConfiguration   pcc.PortusConfiguration
 	CephConfiguration     pcc.CephConfiguration